@@ -0,0 +1,291 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/stuphi/GearGen/gear"
+	"github.com/stuphi/GearGen/plot"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A design read from a JSON file. Each value stands for the command line
+// flag named in its flag tag, and anything left out keeps the default of
+// that flag. The values of each gear are for the flags of the first or
+// second gear, in order, or for the sun and ring of a planetary set. A train
+// of gears has no flags and takes the place of the pair.
+type Config struct {
+	Module        *float64      `json:"module" flag:"m"`
+	Centres       *float64      `json:"centres" flag:"c"`
+	PressureAngle *float64      `json:"pressure_angle" flag:"p"`
+	Backlash      *float64      `json:"backlash" flag:"b"`
+	TipRadius     *float64      `json:"tip_radius" flag:"rf"`
+	Helix         *float64      `json:"helix" flag:"helix"`
+	Hand          *string       `json:"hand" flag:"hand"`
+	Face          *float64      `json:"face" flag:"face"`
+	Density       *float64      `json:"density" flag:"density"`
+	Kerf          *float64      `json:"kerf" flag:"kerf"`
+	Internal      *bool         `json:"internal" flag:"i"`
+	Rim           *float64      `json:"rim" flag:"rim"`
+	Gears         []GearConfig  `json:"gears"`
+	Rack          RackConfig    `json:"rack"`
+	Train         []TrainConfig `json:"train" flag:"-"`
+	Planetary     struct {
+		Planets *int    `json:"planets" flag:"planets"`
+		Fixed   *string `json:"fixed" flag:"fixed"`
+	} `json:"planetary"`
+	Search struct {
+		Ratio      *float64  `json:"ratio" flag:"ratio"`
+		Tolerance  *float64  `json:"tolerance" flag:"tol"`
+		Modules    []float64 `json:"modules" flag:"modules"`
+		MinTeeth   *int      `json:"min_teeth" flag:"nmin"`
+		MaxTeeth   *int      `json:"max_teeth" flag:"nmax"`
+		MinCentres *float64  `json:"min_centres" flag:"cmin"`
+		MaxCentres *float64  `json:"max_centres" flag:"cmax"`
+		MinContact *float64  `json:"min_contact_ratio" flag:"crmin"`
+		Stages     *int      `json:"stages" flag:"stages"`
+		Results    *int      `json:"results" flag:"results"`
+		JSON       *bool     `json:"json" flag:"json"`
+	} `json:"search"`
+	Output struct {
+		File     *string  `json:"file" flag:"o"`
+		Formats  []string `json:"formats" flag:"f"`
+		Cut      *bool    `json:"cut" flag:"cut"`
+		Rotation *int     `json:"rotation" flag:"r"`
+		Report   *bool    `json:"report" flag:"t"`
+		ASCII    *bool    `json:"ascii" flag:"ascii"`
+	} `json:"output"`
+	Style struct {
+		Annotate  *bool             `json:"annotate" flag:"a"`
+		Precision *int              `json:"precision" flag:"prec"`
+		Animate   *float64          `json:"animate" flag:"anim"`
+		Lines     map[string]string `json:"lines" flag:"style"`
+	} `json:"style"`
+	GCode struct {
+		Tool      *float64 `json:"tool" flag:"tool"`
+		Depth     *float64 `json:"depth" flag:"depth"`
+		Step      *float64 `json:"step" flag:"step"`
+		Feed      *float64 `json:"feed" flag:"feed"`
+		Plunge    *float64 `json:"plunge" flag:"plunge"`
+		RPM       *float64 `json:"rpm" flag:"rpm"`
+		Safe      *float64 `json:"safe" flag:"safe"`
+		Tabs      *int     `json:"tabs" flag:"tabs"`
+		TabWidth  *float64 `json:"tab_width" flag:"tabw"`
+		TabHeight *float64 `json:"tab_height" flag:"tabh"`
+		Lead      *float64 `json:"lead" flag:"lead"`
+	} `json:"gcode"`
+}
+
+// The values for one gear of a design. The flag tags are numbered after the
+// gear.
+type GearConfig struct {
+	Teeth    *int     `json:"teeth" flag:"n"`
+	Shift    *float64 `json:"shift" flag:"x"`
+	Bore     *float64 `json:"bore" flag:"bore"`
+	BoreType *string  `json:"bore_type" flag:"bt"`
+	Flat     *float64 `json:"flat" flag:"flat"`
+	Web      *string  `json:"web" flag:"web"`
+	WebCount *int     `json:"web_count" flag:"wn"`
+	WebSize  *float64 `json:"web_size" flag:"ws"`
+	WebRim   *float64 `json:"web_rim" flag:"webrim"`
+	WebHub   *float64 `json:"web_hub" flag:"webhub"`
+	WebPCD   *float64 `json:"web_pcd" flag:"webpcd"`
+}
+
+// The values for a rack in place of the second gear.
+type RackConfig struct {
+	Teeth  *int     `json:"teeth" flag:"rack"`
+	Length *float64 `json:"length" flag:"rl"`
+}
+
+// A gear of a train. Each gear is driven by the one before it unless From
+// says otherwise, counting the first gear as 1.
+type TrainConfig struct {
+	Teeth    int     `json:"teeth"`
+	From     *int    `json:"from"`
+	Compound bool    `json:"compound"`
+	Angle    float64 `json:"angle"`
+	Internal bool    `json:"internal"`
+	Shift    float64 `json:"shift"`
+}
+
+// Read the design in file fname. Names the file does not know are an error,
+// to catch mistakes. Only JSON is read, and a YAML file is refused by its
+// name rather than reported as bad JSON.
+func readConfig(fname string) (Config, error) {
+	var c Config
+	switch strings.ToLower(filepath.Ext(fname)) {
+	case ".yaml", ".yml":
+		return c, fmt.Errorf("%s: designs are read from JSON files only, "+
+			"not YAML", fname)
+	}
+	f, err := os.Open(fname)
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+	d := json.NewDecoder(f)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		return c, fmt.Errorf("%s: %v", fname, err)
+	}
+	if len(c.Gears) > 2 {
+		return c, fmt.Errorf("%s: a design has at most two gears, not %d",
+			fname, len(c.Gears))
+	}
+	if len(c.Gears) > 0 && len(c.Train) > 0 {
+		return c, fmt.Errorf("%s: a design has either gears or a train, "+
+			"not both", fname)
+	}
+	return c, nil
+}
+
+// Return the flag settings of the design as name and value pairs, in the
+// order the values are given.
+func (c Config) settings() [][2]string {
+	return settings(reflect.ValueOf(c), "", nil)
+}
+
+// Add the settings of the values in struct v to retval, with suffix added
+// to each flag name, and return the result.
+func settings(v reflect.Value, suffix string, retval [][2]string) [][2]string {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		name := f.Tag.Get("flag") + suffix
+		switch {
+		case f.Tag.Get("flag") == "-":
+		case fv.Kind() == reflect.Struct:
+			retval = settings(fv, suffix, retval)
+		case fv.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Struct:
+			for j := 0; j < fv.Len(); j++ {
+				retval = settings(fv.Index(j), strconv.Itoa(j+1), retval)
+			}
+		case fv.IsNil():
+		case fv.Kind() == reflect.Slice:
+			s := make([]string, fv.Len())
+			for j := range s {
+				s[j] = fmt.Sprint(fv.Index(j).Interface())
+			}
+			retval = append(retval, [2]string{name, strings.Join(s, ",")})
+		case fv.Kind() == reflect.Map:
+			keys := make([]string, 0, fv.Len())
+			for _, k := range fv.MapKeys() {
+				keys = append(keys, k.String())
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				retval = append(retval, [2]string{name,
+					k + "=" + fv.MapIndex(reflect.ValueOf(k)).String()})
+			}
+		default:
+			retval = append(retval, [2]string{name,
+				fmt.Sprint(fv.Elem().Interface())})
+		}
+	}
+	return retval
+}
+
+// Read the design in file fname and set the flags from it. Flags given on
+// the command line take precedence over the file.
+func loadConfig(fname string) (Config, error) {
+	c, err := readConfig(fname)
+	if err != nil {
+		return c, err
+	}
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for _, s := range c.settings() {
+		if given[s[0]] {
+			continue
+		}
+		if err := flag.Set(s[0], s[1]); err != nil {
+			return c, fmt.Errorf("%s: %s: %v", fname, s[0], err)
+		}
+	}
+	return c, nil
+}
+
+// Return the train of gears of the design, each made by newGear with its
+// number of teeth. The first gear has the hand given, and the rest are
+// made to mesh.
+func (c Config) train(newGear func(n int) gear.Gear,
+	hand gear.Hand) (gear.Train, error) {
+	var t gear.Train
+	for i, tc := range c.Train {
+		tg := gear.TrainGear{Gear: newGear(tc.Teeth), From: i - 1,
+			Compound: tc.Compound, Angle: tc.Angle}
+		if tc.From != nil {
+			tg.From = *tc.From - 1
+		}
+		if i > 0 && (tg.From < 0 || tg.From >= i) {
+			return t, fmt.Errorf("train gear %d must be driven by an "+
+				"earlier gear", i+1)
+		}
+		tg.Gear.X = tc.Shift
+		tg.Gear.Internal = tc.Internal
+		tg.Gear.Hand = hand
+		if i > 0 {
+			d := t.Gears[tg.From].Gear
+			tg.Gear.Hand = d.Hand
+			if !tg.Compound && !d.Internal && !tc.Internal {
+				tg.Gear.Hand = d.Hand.Opposite()
+			}
+		}
+		t.Gears = append(t.Gears, tg)
+	}
+	return t, t.Check()
+}
+
+// Line styles for svg drawings, given as name=css for each line type.
+type styles map[string]string
+
+// Return the styles as they would be given on the command line.
+func (s styles) String() string {
+	var retval []string
+	for _, name := range plot.Styles {
+		if css, ok := s[name]; ok {
+			retval = append(retval, name+"="+css)
+		}
+	}
+	return strings.Join(retval, " ")
+}
+
+// Set the style of one line type from name=css.
+func (s styles) Set(v string) error {
+	i := strings.Index(v, "=")
+	if i < 0 {
+		return fmt.Errorf("%q is not name=css", v)
+	}
+	for _, name := range plot.Styles {
+		if name == v[:i] {
+			s[name] = v[i+1:]
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown line type %q, want one of %s", v[:i],
+		strings.Join(plot.Styles, ", "))
+}
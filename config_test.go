@@ -0,0 +1,131 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/stuphi/GearGen/gear"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSettings(t *testing.T) {
+	var c Config
+	err := json.Unmarshal([]byte(`{
+		"module": 1.5,
+		"centres": 40.25,
+		"gears": [{"teeth": 12}, {"teeth": 30, "bore": 8}],
+		"output": {"formats": ["svg", "dxf"], "cut": true},
+		"style": {"lines": {"solid": "stroke:red", "dash": "stroke:blue"}},
+		"gcode": {"tab_width": 4}
+	}`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]string{
+		{"m", "1.5"},
+		{"c", "40.25"},
+		{"n1", "12"},
+		{"n2", "30"},
+		{"bore2", "8"},
+		{"f", "svg,dxf"},
+		{"cut", "true"},
+		{"style", "dash=stroke:blue"},
+		{"style", "solid=stroke:red"},
+		{"tabw", "4"},
+	}
+	if got := c.settings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("settings() == %v, want %v", got, want)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	for name, s := range map[string]string{
+		"d.json": `{"module": 2, "gears": [{"teeth": 12}, {"teeth": 30}]}`,
+		"d.yaml": "module: 2\n",
+		"d.YML":  "module: 2\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(s),
+			0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := readConfig(filepath.Join(dir, "d.json"))
+	if err != nil || c.Module == nil || *c.Module != 2 || len(c.Gears) != 2 {
+		t.Errorf("readConfig() of JSON returned %+v, %v", c, err)
+	}
+	for _, name := range []string{"d.yaml", "d.YML"} {
+		if _, err := readConfig(filepath.Join(dir, name)); err == nil ||
+			!strings.Contains(err.Error(), "JSON files only") {
+			t.Errorf("readConfig(%s) returned %v, want JSON only", name, err)
+		}
+	}
+}
+
+func TestStylesFlag(t *testing.T) {
+	s := styles{}
+	if err := s.Set("grid=stroke:none"); err != nil {
+		t.Errorf("Set() returned %v", err)
+	}
+	if s["grid"] != "stroke:none" {
+		t.Errorf("Set() gave %v", s)
+	}
+	for _, v := range []string{"grid", "bold=stroke:black"} {
+		if err := s.Set(v); err == nil {
+			t.Errorf("Set(%q) returned no error", v)
+		}
+	}
+}
+
+func TestTrain(t *testing.T) {
+	from := 1
+	c := Config{Train: []TrainConfig{
+		{Teeth: 12},
+		{Teeth: 36, Angle: 30},
+		{Teeth: 14, Compound: true},
+		{Teeth: 60, Internal: true, From: &from, Angle: 180},
+	}}
+	tr, err := c.train(func(n int) gear.Gear {
+		return gear.FromNormal(1, n, 20, 0, 15)
+	}, gear.RightHand)
+	if err != nil {
+		t.Fatalf("train() returned %v", err)
+	}
+	// Meshing external gears have opposite hands, and an internal gear the
+	// same hand as its pinion.
+	want := []gear.Hand{gear.RightHand, gear.LeftHand, gear.LeftHand,
+		gear.RightHand}
+	for i, tg := range tr.Gears {
+		if tg.Gear.Hand != want[i] {
+			t.Errorf("gear %d has a %v hand, want %v", i+1, tg.Gear.Hand, want[i])
+		}
+	}
+	if tr.Gears[3].From != 0 {
+		t.Errorf("gear 4 is driven by gear %d, want 1", tr.Gears[3].From+1)
+	}
+	bad := -1
+	c.Train[3].From = &bad
+	if _, err := c.train(func(n int) gear.Gear {
+		return gear.FromModule(1, n, 20, 0)
+	}, gear.RightHand); err == nil {
+		t.Error("train() with a gear driven by gear -1 returned no error")
+	}
+}
@@ -0,0 +1,268 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// A package to convert our gears to an ASCII DXF drawing for CAD and CAM.
+package dxf
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/stuphi/GearGen/gear"
+	"io"
+	"math"
+)
+
+// Names of the layers used in the drawing, with their colour numbers.
+const (
+	LayerOutline = "OUTLINE"
+	LayerPitch   = "PITCH"
+	LayerCentre  = "CENTRE"
+)
+
+var layerColours = []struct {
+	name   string
+	colour int
+}{
+	{LayerOutline, 7},
+	{LayerPitch, 3},
+	{LayerCentre, 1},
+}
+
+// A DXF drawing built up from entities. All dimensions are in mm.
+type Drawing struct {
+	entities []pair
+}
+
+// A group code and its value. Everything in a DXF file is written as these.
+type pair struct {
+	code  int
+	value string
+}
+
+// Format a dimension for the file. Six decimal places is a nanometre, which
+// is well beyond what any machine can cut.
+func num(f float64) string {
+	s := fmt.Sprintf("%.6f", f)
+	if s == "-0.000000" {
+		s = "0.000000"
+	}
+	return s
+}
+
+// Add a group code and its value to the entities of the drawing.
+func (d *Drawing) add(code int, value string) {
+	d.entities = append(d.entities, pair{code, value})
+}
+
+// Add a line from p1 to p2 on the given layer.
+func (d *Drawing) Line(layer string, p1, p2 gear.Point) {
+	d.add(0, "LINE")
+	d.add(8, layer)
+	d.add(10, num(p1.X))
+	d.add(20, num(p1.Y))
+	d.add(11, num(p2.X))
+	d.add(21, num(p2.Y))
+}
+
+// Add a circle of radius r about c on the given layer.
+func (d *Drawing) Circle(layer string, c gear.Point, r float64) {
+	d.add(0, "CIRCLE")
+	d.add(8, layer)
+	d.add(10, num(c.X))
+	d.add(20, num(c.Y))
+	d.add(40, num(r))
+}
+
+// Add an outline as a single closed POLYLINE on the given layer. Arcs keep
+// their true shape by setting the bulge of the vertex they start from.
+func (d *Drawing) Outline(layer string, o gear.Outline) {
+	d.add(0, "POLYLINE")
+	d.add(8, layer)
+	d.add(66, "1") // Vertices follow
+	d.add(10, num(0))
+	d.add(20, num(0))
+	d.add(30, num(0))
+	d.add(70, "1") // Closed
+	vertex := func(p gear.Point, bulge float64) {
+		d.add(0, "VERTEX")
+		d.add(8, layer)
+		d.add(10, num(p.X))
+		d.add(20, num(p.Y))
+		d.add(30, num(0))
+		if bulge != 0 {
+			d.add(42, num(bulge))
+		}
+	}
+	for _, s := range o {
+		if s.Kind == gear.Arc {
+			vertex(s.Start(), math.Tan(s.Sweep()/4))
+			continue
+		}
+		for _, p := range s.Points[:len(s.Points)-1] {
+			vertex(p, 0)
+		}
+	}
+	d.add(0, "SEQEND")
+	d.add(8, layer)
+}
+
+// Add a gear centred on c and turned through rot degrees. The outline, the
+// pitch circle and a centre mark each go on their own layer. Any holes, and
+// the rim of an internal gear, go on the outline layer.
+func (d *Drawing) Gear(g gear.Gear, c gear.Point, rot float64) {
+	d.Outline(LayerOutline, g.Outline().Rotate(rot*gear.DegToRad).
+		Translate(c.X, c.Y))
+	for _, h := range g.Holes() {
+		d.Outline(LayerOutline, h.Rotate(rot*gear.DegToRad).Translate(c.X, c.Y))
+	}
+	if g.Internal {
+		d.Circle(LayerOutline, c, g.GetRimDia()/2)
+	}
+	d.Circle(LayerPitch, c, g.Pd/2)
+	l := g.GetOutsideDia() / 8
+	d.Line(LayerCentre, gear.Point{X: c.X - l, Y: c.Y},
+		gear.Point{X: c.X + l, Y: c.Y})
+	d.Line(LayerCentre, gear.Point{X: c.X, Y: c.Y - l},
+		gear.Point{X: c.X, Y: c.Y + l})
+}
+
+// Add a rack with the centre of its pitch line at c. The outline and the
+// pitch line each go on their own layer.
+func (d *Drawing) Rack(r gear.Rack, c gear.Point) {
+	d.Outline(LayerOutline, r.Outline().Translate(c.X, c.Y))
+	l := r.GetLength() / 2
+	d.Line(LayerPitch, gear.Point{X: c.X - l, Y: c.Y},
+		gear.Point{X: c.X + l, Y: c.Y})
+}
+
+// Write the complete drawing to w as an R12 file, which needs no handles
+// or objects and is read by every CAD and CAM program. R12 does not record
+// units, so they are only given in the comment at the top.
+func (d *Drawing) Write(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	put := func(code int, value string) {
+		fmt.Fprintf(b, "%3d\n%s\n", code, value)
+	}
+	put(999, "GearGen drawing, all dimensions in mm")
+	put(0, "SECTION")
+	put(2, "HEADER")
+	put(9, "$ACADVER")
+	put(1, "AC1009")
+	put(0, "ENDSEC")
+	put(0, "SECTION")
+	put(2, "TABLES")
+	put(0, "TABLE")
+	put(2, "LTYPE")
+	put(70, "1")
+	put(0, "LTYPE")
+	put(2, "CONTINUOUS")
+	put(70, "0")
+	put(3, "Solid line")
+	put(72, "65")
+	put(73, "0")
+	put(40, num(0))
+	put(0, "ENDTAB")
+	put(0, "TABLE")
+	put(2, "LAYER")
+	put(70, fmt.Sprintf("%d", len(layerColours)))
+	for _, l := range layerColours {
+		put(0, "LAYER")
+		put(2, l.name)
+		put(70, "0")
+		put(62, fmt.Sprintf("%d", l.colour))
+		put(6, "CONTINUOUS")
+	}
+	put(0, "ENDTAB")
+	put(0, "ENDSEC")
+	put(0, "SECTION")
+	put(2, "ENTITIES")
+	for _, e := range d.entities {
+		put(e.code, e.value)
+	}
+	put(0, "ENDSEC")
+	put(0, "EOF")
+	return b.Flush()
+}
+
+// Write a drawing of the pair of gears p to w. The first gear is centred on
+// the origin with the second placed as given by p.GetOffset, and rotfrac is
+// the percentage of one tooth to rotate both gears, as for plot.Plot.
+func Plot(w io.Writer, p gear.Pair, rotfrac int) error {
+	if err := p.Check(); err != nil {
+		return err
+	}
+	var d Drawing
+	rot1, rot2 := p.GetRotations(float64(rotfrac))
+	d.Gear(p.G1, gear.Point{}, rot1)
+	d.Gear(p.G2, gear.Point{X: p.GetOffset()}, rot2)
+	return d.Write(w)
+}
+
+// Write a drawing of the rack and pinion p to w. The pitch line of the rack
+// runs along the x axis with the pinion above it, and rotfrac is the
+// percentage of one tooth to turn the pinion, as for plot.Plot.
+func PlotRack(w io.Writer, p gear.RackPair, rotfrac int) error {
+	if err := p.Check(); err != nil {
+		return err
+	}
+	var d Drawing
+	rot, travel := p.GetRotation(float64(rotfrac))
+	d.Gear(p.G, gear.Point{Y: p.GetCentreHeight()}, rot)
+	d.Rack(p.R, gear.Point{X: travel})
+	return d.Write(w)
+}
+
+// Write a drawing of the gear train t to w, with the first gear on the
+// origin. rotfrac is the percentage of one tooth to turn the first gear, as
+// for plot.Plot.
+func PlotTrain(w io.Writer, t gear.Train, rotfrac int) error {
+	ps, err := t.Layout(float64(rotfrac))
+	if err != nil {
+		return err
+	}
+	var d Drawing
+	for i, p := range ps {
+		d.Gear(t.Gears[i].Gear, p.Centre, p.Rot)
+	}
+	return d.Write(w)
+}
+
+// Write the planetary set p to w, with the input turned by rotfrac percent
+// of a tooth while member fixed is held, as for PlotTrain.
+func PlotPlanetary(w io.Writer, p gear.Planetary, fixed gear.Member,
+	rotfrac int) error {
+	l, err := p.Layout(fixed, float64(rotfrac))
+	if err != nil {
+		return err
+	}
+	var d Drawing
+	d.Gear(p.Ring, l.Ring.Centre, l.Ring.Rot)
+	d.Gear(p.Sun, l.Sun.Centre, l.Sun.Rot)
+	for _, pp := range l.Planets {
+		d.Gear(p.Planet, pp.Centre, pp.Rot)
+	}
+	return d.Write(w)
+}
+
+// Write the closed contours cs to w on the outline layer alone, ready to
+// cut. The contours are written in the order given.
+func Cut(w io.Writer, cs []gear.Outline) error {
+	var d Drawing
+	for _, o := range cs {
+		d.Outline(LayerOutline, o)
+	}
+	return d.Write(w)
+}
@@ -0,0 +1,169 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package dxf
+
+import (
+	"bytes"
+	"github.com/stuphi/GearGen/gear"
+	"strings"
+	"testing"
+)
+
+func TestOutlineBulge(t *testing.T) {
+	// A half disc: a line across the bottom and a semicircle over the top.
+	o := gear.Outline{
+		{Kind: gear.Line, Points: []gear.Point{{X: -1, Y: 0}, {X: 1, Y: 0}}},
+		{Kind: gear.Arc, Points: []gear.Point{{X: 1, Y: 0}, {X: -1, Y: 0}},
+			Radius: 1},
+	}
+	var d Drawing
+	d.Outline(LayerOutline, o)
+	var b bytes.Buffer
+	if err := d.Write(&b); err != nil {
+		t.Fatalf("Write() returned %v", err)
+	}
+	want := strings.Join([]string{
+		"  0", "POLYLINE", "  8", "OUTLINE", " 66", "1",
+		" 10", "0.000000", " 20", "0.000000", " 30", "0.000000", " 70", "1",
+		"  0", "VERTEX", "  8", "OUTLINE",
+		" 10", "-1.000000", " 20", "0.000000", " 30", "0.000000",
+		"  0", "VERTEX", "  8", "OUTLINE",
+		" 10", "1.000000", " 20", "0.000000", " 30", "0.000000",
+		" 42", "1.000000",
+		"  0", "SEQEND", "  8", "OUTLINE",
+		"  0", "ENDSEC"}, "\n")
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("Outline() wrote %q, want it to contain %q", b.String(), want)
+	}
+}
+
+func TestPlot(t *testing.T) {
+	p := gear.NewPair(gear.FromModule(2, 12, 20, 0), gear.FromModule(2, 30, 20, 0))
+	var b bytes.Buffer
+	if err := Plot(&b, p, 0); err != nil {
+		t.Fatalf("Plot() returned %v", err)
+	}
+	out := b.String()
+	for _, w := range []string{"LAYER\n  2\nOUTLINE", "LAYER\n  2\nPITCH",
+		"LAYER\n  2\nCENTRE"} {
+		if !strings.Contains(out, w) {
+			t.Errorf("Plot() missing layer table entry %q", w)
+		}
+	}
+	counts := map[string]int{"POLYLINE": 2, "CIRCLE": 2, "LINE": 4}
+	for e, want := range counts {
+		if got := strings.Count(out, "  0\n"+e+"\n"); got != want {
+			t.Errorf("Plot() wrote %d %s entities, want %d", got, e, want)
+		}
+	}
+	if !strings.HasSuffix(out, "  0\nEOF\n") {
+		t.Errorf("Plot() output does not end with EOF")
+	}
+	// An R12 file, where each polyline ends with its own SEQEND.
+	if !strings.Contains(out, "  9\n$ACADVER\n  1\nAC1009\n") {
+		t.Errorf("Plot() output is not an R12 file")
+	}
+	if got := strings.Count(out, "  0\nSEQEND\n"); got != 2 {
+		t.Errorf("Plot() wrote %d SEQEND entities, want 2", got)
+	}
+}
+
+func TestPlotInternal(t *testing.T) {
+	g2 := gear.FromModule(2, 40, 20, 0)
+	g2.Internal = true
+	p := gear.NewPair(gear.FromModule(2, 12, 20, 0), g2)
+	var b bytes.Buffer
+	if err := Plot(&b, p, 0); err != nil {
+		t.Fatalf("Plot() returned %v", err)
+	}
+	// The rim of the internal gear adds a third circle.
+	if got := strings.Count(b.String(), "  0\nCIRCLE\n"); got != 3 {
+		t.Errorf("Plot() wrote %d CIRCLE entities, want 3", got)
+	}
+}
+
+func TestPlotRack(t *testing.T) {
+	g := gear.FromModule(2, 12, 20, 0)
+	p := gear.RackPair{G: g, R: gear.RackFor(g, 8)}
+	var b bytes.Buffer
+	if err := PlotRack(&b, p, 25); err != nil {
+		t.Fatalf("PlotRack() returned %v", err)
+	}
+	counts := map[string]int{"POLYLINE": 2, "CIRCLE": 1, "LINE": 3}
+	for e, want := range counts {
+		if got := strings.Count(b.String(), "  0\n"+e+"\n"); got != want {
+			t.Errorf("PlotRack() wrote %d %s entities, want %d", got, e, want)
+		}
+	}
+}
+
+func TestCut(t *testing.T) {
+	g2 := gear.FromModule(2, 40, 20, 0)
+	g2.Internal = true
+	cs := gear.Layout(5, gear.FromModule(2, 12, 20, 0).Contours(),
+		g2.Contours())
+	var b bytes.Buffer
+	if err := Cut(&b, cs); err != nil {
+		t.Fatalf("Cut() returned %v", err)
+	}
+	out := b.String()
+	if got := strings.Count(out, "  0\nPOLYLINE\n"); got != 3 {
+		t.Errorf("Cut() wrote %d POLYLINE entities, want 3", got)
+	}
+	for _, e := range []string{"CIRCLE", "LINE", "PITCH", "CENTRE"} {
+		if strings.Contains(out, "  0\n"+e+"\n") || strings.Contains(out,
+			"  8\n"+e+"\n") {
+			t.Errorf("Cut() wrote %s, want only the contours", e)
+		}
+	}
+}
+
+func TestPlotTrain(t *testing.T) {
+	tr := gear.Train{Gears: []gear.TrainGear{
+		{Gear: gear.FromModule(2, 12, 20, 0)},
+		{Gear: gear.FromModule(2, 30, 20, 0), Angle: 90},
+		{Gear: gear.FromModule(2, 15, 20, 0), From: 1, Compound: true},
+	}}
+	var b bytes.Buffer
+	if err := PlotTrain(&b, tr, 0); err != nil {
+		t.Fatalf("PlotTrain() returned %v", err)
+	}
+	if got := strings.Count(b.String(), "  0\nPOLYLINE\n"); got != 3 {
+		t.Errorf("PlotTrain() wrote %d POLYLINE entities, want 3", got)
+	}
+	tr.Gears[1].From = 2
+	if err := PlotTrain(&b, tr, 0); err == nil {
+		t.Error("PlotTrain() of a gear driven by a later one returned no error")
+	}
+}
+
+func TestPlotPlanetary(t *testing.T) {
+	p := gear.NewPlanetary(gear.FromModule(2, 12, 20, 0),
+		gear.FromModule(2, 48, 20, 0), 4)
+	var b bytes.Buffer
+	if err := PlotPlanetary(&b, p, gear.CarrierMember, 0); err != nil {
+		t.Fatalf("PlotPlanetary() returned %v", err)
+	}
+	if got := strings.Count(b.String(), "  0\nPOLYLINE\n"); got != 6 {
+		t.Errorf("PlotPlanetary() wrote %d POLYLINE entities, want 6", got)
+	}
+	p.Planets = 5
+	if err := PlotPlanetary(&b, p, gear.CarrierMember, 0); err == nil {
+		t.Error("PlotPlanetary() of planets that cannot be spaced evenly " +
+			"returned no error")
+	}
+}
@@ -0,0 +1,272 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// A package to write G-code that mills our gears out of plate on a 3-axis
+// machine. The top of the plate is at z = 0.
+package gcode
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/stuphi/GearGen/gear"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// How far the tool path may stray from the true curves, in mm.
+const Tolerance = 0.01
+
+// Options for milling. All lengths are in mm and feed rates in mm/min.
+type Options struct {
+	ToolDia    float64 // diameter of the cutter
+	Depth      float64 // depth to cut, the thickness of the plate
+	StepDown   float64 // greatest depth of each pass, 0 to cut in one pass
+	Feed       float64 // cutting feed rate
+	PlungeFeed float64 // feed rate down into the plate, 0 for half of Feed
+	Speed      float64 // spindle speed in rpm, 0 to leave the spindle alone
+	SafeZ      float64 // height to move at between cuts
+	Tabs       int     // number of tabs left holding each outside contour
+	TabWidth   float64 // width of each tab
+	TabHeight  float64 // height of each tab above the bottom of the cut
+	LeadIn     float64 // radius of the arcs into and out of each cut
+}
+
+// Return the depth of each pass, from the first to the last, all negative.
+func (o Options) passes() []float64 {
+	n := 1
+	if o.StepDown > 0 {
+		n = int(math.Ceil(o.Depth/o.StepDown - 1e-9))
+	}
+	retval := make([]float64, n)
+	for i := range retval {
+		retval[i] = -o.Depth * float64(i+1) / float64(n)
+	}
+	return retval
+}
+
+// Return the plunge feed rate.
+func (o Options) plungeFeed() float64 {
+	if o.PlungeFeed > 0 {
+		return o.PlungeFeed
+	}
+	return o.Feed / 2
+}
+
+// Format a number for the program, with any trailing zeros dropped.
+func num(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 4, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
+
+// A program being written to w. The feed rate is only written when it
+// changes.
+type program struct {
+	w    *bufio.Writer
+	feed float64
+}
+
+// Write one line of the program.
+func (p *program) line(format string, a ...interface{}) {
+	fmt.Fprintf(p.w, format+"\n", a...)
+}
+
+// Return the feed word for rate f, or nothing if it is already set.
+func (p *program) f(f float64) string {
+	if f == p.feed {
+		return ""
+	}
+	p.feed = f
+	return " F" + num(f)
+}
+
+// Move in a straight line to pt at feed rate f.
+func (p *program) to(pt gear.Point, f float64) {
+	p.line("G1 X%s Y%s%s", num(pt.X), num(pt.Y), p.f(f))
+}
+
+// Move straight up or down to z at feed rate f.
+func (p *program) toZ(z float64, f float64) {
+	p.line("G1 Z%s%s", num(z), p.f(f))
+}
+
+// Move clockwise round an arc about c from the current point, at pt, to
+// end, at feed rate f.
+func (p *program) arc(pt, end, c gear.Point, f float64) {
+	p.line("G2 X%s Y%s I%s J%s%s", num(end.X), num(end.Y), num(c.X-pt.X),
+		num(c.Y-pt.Y), p.f(f))
+}
+
+// Return the unit vector from a to b.
+func unit(a, b gear.Point) gear.Point {
+	l := math.Hypot(b.X-a.X, b.Y-a.Y)
+	return gear.Point{X: (b.X - a.X) / l, Y: (b.Y - a.Y) / l}
+}
+
+// Return the tool paths for the contours cs. The centre of the cutter runs
+// on the waste side of each contour, outside the part and inside the holes,
+// and the paths run the same way as the contours. A contour the cutter
+// cannot follow at all returns an error.
+func Paths(cs []gear.Outline, opts Options) ([][]gear.Point, error) {
+	var retval [][]gear.Point
+	for i, c := range cs {
+		offsets := c.Offset(opts.ToolDia/2, Tolerance)
+		if len(offsets) == 0 {
+			return nil, fmt.Errorf("contour %d is too small for a %s mm "+
+				"cutter", i+1, num(opts.ToolDia))
+		}
+		for _, o := range offsets {
+			retval = append(retval, o.Polygon(Tolerance))
+		}
+	}
+	return retval, nil
+}
+
+// Return the area of a closed path, positive if it runs anticlockwise.
+func area(pts []gear.Point) float64 {
+	var a float64
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		a += p.X*q.Y - q.X*p.Y
+	}
+	return a / 2
+}
+
+// Return the distances along the closed path at which each tab starts and
+// ends. The tabs are spaced evenly, and along the path each is widened by
+// the cutter so that TabWidth of material is left.
+func (o Options) tabs(pts []gear.Point) [][2]float64 {
+	if o.Tabs < 1 || o.TabHeight <= 0 {
+		return nil
+	}
+	var l float64
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		l += math.Hypot(q.X-p.X, q.Y-p.Y)
+	}
+	w := o.TabWidth + o.ToolDia
+	if w*float64(o.Tabs) >= l {
+		return nil
+	}
+	retval := make([][2]float64, o.Tabs)
+	for i := range retval {
+		mid := l * (float64(i) + 0.5) / float64(o.Tabs)
+		retval[i] = [2]float64{mid - w/2, mid + w/2}
+	}
+	return retval
+}
+
+// Write the moves that follow the closed path at depth z, lifting to tabZ
+// over the tabs.
+func (p *program) follow(pts []gear.Point, z, tabZ float64,
+	tabs [][2]float64, opts Options) {
+	type event struct {
+		at float64
+		up bool
+	}
+	var events []event
+	if z < tabZ {
+		for _, t := range tabs {
+			events = append(events, event{t[0], true}, event{t[1], false})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].at < events[j].at })
+	var l float64
+	for i, a := range pts {
+		b := pts[(i+1)%len(pts)]
+		sl := math.Hypot(b.X-a.X, b.Y-a.Y)
+		for len(events) > 0 && events[0].at <= l+sl {
+			e := events[0]
+			events = events[1:]
+			t := (e.at - l) / sl
+			p.to(gear.Point{X: a.X + t*(b.X-a.X), Y: a.Y + t*(b.Y-a.Y)},
+				opts.Feed)
+			if e.up {
+				p.toZ(tabZ, opts.Feed)
+			} else {
+				p.toZ(z, opts.plungeFeed())
+			}
+		}
+		p.to(b, opts.Feed)
+		l += sl
+	}
+}
+
+// Write a program to w that mills out the closed contours cs in the order
+// given, as returned by gear.Contours or gear.Layout. Each contour is cut in
+// passes down to the full depth, going in and out on arcs clear of the part.
+// Tabs are left on contours that run anticlockwise, the outsides of parts.
+func Cut(w io.Writer, cs []gear.Outline, opts Options) error {
+	paths, err := Paths(cs, opts)
+	if err != nil {
+		return err
+	}
+	p := program{w: bufio.NewWriter(w)}
+	p.line("(Generated by GearGen)")
+	p.line("(Cutter %s mm, depth %s mm)", num(opts.ToolDia), num(opts.Depth))
+	p.line("G21 G90 G17")
+	p.line("G0 Z%s", num(opts.SafeZ))
+	if opts.Speed > 0 {
+		p.line("M3 S%s", num(opts.Speed))
+	}
+	tabZ := -opts.Depth + opts.TabHeight
+	for _, pts := range paths {
+		var tabs [][2]float64
+		// The waste is to the right of the path, so go in and out round
+		// arcs on that side. In a hole they must be small enough to stay
+		// inside it.
+		r := opts.LeadIn
+		if a := area(pts); a > 0 {
+			tabs = opts.tabs(pts)
+		} else {
+			r = math.Min(r, math.Sqrt(-a/math.Pi)/2)
+		}
+		start := pts[0]
+		t0 := unit(pts[len(pts)-1], start)
+		t1 := unit(start, pts[1])
+		in, out := start, start
+		var cin, cout gear.Point
+		if r > 0 {
+			cin = gear.Point{X: start.X + r*t1.Y, Y: start.Y - r*t1.X}
+			in = gear.Point{X: cin.X - r*t1.X, Y: cin.Y - r*t1.Y}
+			cout = gear.Point{X: start.X + r*t0.Y, Y: start.Y - r*t0.X}
+			out = gear.Point{X: cout.X + r*t0.X, Y: cout.Y + r*t0.Y}
+		}
+		for _, z := range opts.passes() {
+			p.line("G0 X%s Y%s", num(in.X), num(in.Y))
+			p.toZ(z, opts.plungeFeed())
+			if in != start {
+				p.arc(in, start, cin, opts.Feed)
+			}
+			p.follow(pts, z, tabZ, tabs, opts)
+			if out != start {
+				p.arc(start, out, cout, opts.Feed)
+			}
+			p.line("G0 Z%s", num(opts.SafeZ))
+		}
+	}
+	if opts.Speed > 0 {
+		p.line("M5")
+	}
+	p.line("M2")
+	return p.w.Flush()
+}
@@ -0,0 +1,111 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gcode
+
+import (
+	"bytes"
+	"github.com/stuphi/GearGen/gear"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A 20 mm square, running anticlockwise.
+var square = gear.Outline{
+	{Kind: gear.Line, Points: []gear.Point{{X: 0, Y: 0}, {X: 20, Y: 0},
+		{X: 20, Y: 20}, {X: 0, Y: 20}, {X: 0, Y: 0}}},
+}
+
+func TestPasses(t *testing.T) {
+	for _, c := range []struct {
+		depth, step float64
+		want        []float64
+	}{
+		{6, 0, []float64{-6}},
+		{6, 2, []float64{-2, -4, -6}},
+		{6, 2.5, []float64{-2, -4, -6}},
+		{6, 6, []float64{-6}},
+	} {
+		got := Options{Depth: c.depth, StepDown: c.step}.passes()
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("passes() for %g in steps of %g == %v, want %v", c.depth,
+				c.step, got, c.want)
+		}
+	}
+}
+
+func TestPaths(t *testing.T) {
+	hole := gear.Outline{
+		{Kind: gear.Line, Points: []gear.Point{{X: 5, Y: 5}, {X: 5, Y: 15},
+			{X: 15, Y: 15}, {X: 15, Y: 5}, {X: 5, Y: 5}}},
+	}
+	ps, err := Paths([]gear.Outline{hole, square}, Options{ToolDia: 2})
+	if err != nil {
+		t.Fatalf("Paths() returned %v", err)
+	}
+	if len(ps) != 2 {
+		t.Fatalf("Paths() returned %d paths, want 2", len(ps))
+	}
+	// The hole shrinks by the radius of the cutter and the outside grows,
+	// round the corners. The chords of the corners lie just outside the
+	// true arcs.
+	if got := area(ps[0]); math.Abs(got+64) > 1e-6 {
+		t.Errorf("hole path has area %.4f, want -64", got)
+	}
+	if got, want := area(ps[1]), 400+4*20+math.Pi; got < want || got-want > 0.05 {
+		t.Errorf("outside path has area %.4f, want %.4f", got, want)
+	}
+	if _, err := Paths([]gear.Outline{hole}, Options{ToolDia: 12}); err == nil {
+		t.Error("Paths() with a cutter too big for the hole returned no error")
+	}
+}
+
+func TestCut(t *testing.T) {
+	opts := Options{ToolDia: 2, Depth: 3, StepDown: 1.5, Feed: 600,
+		Speed: 10000, SafeZ: 5, Tabs: 2, TabWidth: 3, TabHeight: 1, LeadIn: 2}
+	var b bytes.Buffer
+	if err := Cut(&b, []gear.Outline{square}, opts); err != nil {
+		t.Fatalf("Cut() returned %v", err)
+	}
+	s := b.String()
+	for _, want := range []string{
+		"G21 G90 G17\nG0 Z5\nM3 S10000\n",
+		"G1 Z-1.5 F300\n",
+		"G1 Z-3 F300\n",
+		"G1 Z-2\n",
+		"M5\nM2\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Cut() wrote no %q", want)
+		}
+	}
+	// Two passes, each in and out on an arc, with the tabs only on the
+	// second.
+	if got := strings.Count(s, "G2 "); got != 4 {
+		t.Errorf("Cut() wrote %d arcs, want 4", got)
+	}
+	if got := strings.Count(s, "G1 Z-2\n"); got != 2 {
+		t.Errorf("Cut() rose over %d tabs, want 2", got)
+	}
+	// The cut starts at the top right of the square, a cutter radius to
+	// the right, and goes in from further out.
+	if !strings.Contains(s, "G0 X23 Y18\nG1 Z-1.5 F300\n"+
+		"G2 X21 Y20 I0 J2 F600\n") {
+		t.Errorf("Cut() did not go in round an arc, wrote %q", s)
+	}
+}
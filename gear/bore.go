@@ -0,0 +1,229 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"fmt"
+	"math"
+)
+
+// The kinds of centre bore a gear can have.
+type BoreKind int
+
+const (
+	RoundBore   BoreKind = iota // Plain round hole
+	DIN6885Bore                 // Round with a metric keyway to DIN 6885
+	ANSIBore                    // Round with an inch keyway to ANSI B17.1
+	DFlatBore                   // Round with one flat side
+	HexBore                     // Hexagon, sized across the flats
+)
+
+var boreNames = []string{"round", "din6885", "ansi", "dflat", "hex"}
+
+// Return the name of the kind of bore, as given to ParseBoreKind.
+func (k BoreKind) String() string {
+	if k < 0 || int(k) >= len(boreNames) {
+		return fmt.Sprintf("BoreKind(%d)", int(k))
+	}
+	return boreNames[k]
+}
+
+// Return the kind of bore with the given name.
+func ParseBoreKind(s string) (BoreKind, error) {
+	for i, n := range boreNames {
+		if n == s {
+			return BoreKind(i), nil
+		}
+	}
+	return RoundBore, fmt.Errorf("unknown bore type %q", s)
+}
+
+// Structure to hold the centre bore of a gear. A keyway or flat is on the
+// positive x axis, in line with the first tooth.
+type Bore struct {
+	Dia  float64 // diameter, or distance across the flats of a hex
+	Kind BoreKind
+	Flat float64 // distance across a D-flat, 0 for 0.9 of the diameter
+}
+
+// One row of a keyway table. Shafts over Over and up to To take a key of
+// width Width, cut Depth into the hub.
+type keySize struct {
+	Over, To, Width, Depth float64
+}
+
+// Parallel keys to DIN 6885-1, in mm.
+var din6885 = []keySize{
+	{6, 8, 2, 1.0},
+	{8, 10, 3, 1.4},
+	{10, 12, 4, 1.8},
+	{12, 17, 5, 2.3},
+	{17, 22, 6, 2.8},
+	{22, 30, 8, 3.3},
+	{30, 38, 10, 3.3},
+	{38, 44, 12, 3.3},
+	{44, 50, 14, 3.8},
+	{50, 58, 16, 4.3},
+	{58, 65, 18, 4.4},
+	{65, 75, 20, 4.9},
+	{75, 85, 22, 5.4},
+	{85, 95, 25, 5.4},
+	{95, 110, 28, 6.4},
+	{110, 130, 32, 7.4},
+}
+
+// Square keys to ANSI B17.1, in inches. Half the key sits in the hub.
+var ansiB171 = []keySize{
+	{5.0 / 16, 7.0 / 16, 3.0 / 32, 3.0 / 64},
+	{7.0 / 16, 9.0 / 16, 1.0 / 8, 1.0 / 16},
+	{9.0 / 16, 7.0 / 8, 3.0 / 16, 3.0 / 32},
+	{7.0 / 8, 5.0 / 4, 1.0 / 4, 1.0 / 8},
+	{5.0 / 4, 11.0 / 8, 5.0 / 16, 5.0 / 32},
+	{11.0 / 8, 7.0 / 4, 3.0 / 8, 3.0 / 16},
+	{7.0 / 4, 9.0 / 4, 1.0 / 2, 1.0 / 4},
+	{9.0 / 4, 11.0 / 4, 5.0 / 8, 5.0 / 16},
+	{11.0 / 4, 13.0 / 4, 3.0 / 4, 3.0 / 8},
+	{13.0 / 4, 15.0 / 4, 7.0 / 8, 7.0 / 16},
+	{15.0 / 4, 9.0 / 2, 1, 1.0 / 2},
+	{9.0 / 2, 11.0 / 2, 5.0 / 4, 5.0 / 8},
+}
+
+// Return the width of the keyway and its depth into the hub, measured from
+// the bore, in mm. An error is returned if the bore has no keyway or is
+// outside the range of the standard.
+func (b Bore) GetKeyway() (float64, float64, error) {
+	table, scale, name := din6885, 1.0, "DIN 6885"
+	switch b.Kind {
+	case DIN6885Bore:
+	case ANSIBore:
+		table, scale, name = ansiB171, 25.4, "ANSI B17.1"
+	default:
+		return 0, 0, fmt.Errorf("a %s bore has no keyway", b.Kind)
+	}
+	for _, k := range table {
+		if b.Dia > k.Over*scale && b.Dia <= k.To*scale {
+			return k.Width * scale, k.Depth * scale, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("no %s keyway for a %.3f mm bore, it must be "+
+		"over %.3f and up to %.3f mm", name, b.Dia, table[0].Over*scale,
+		table[len(table)-1].To*scale)
+}
+
+// Return the distance across a D-flat.
+func (b Bore) GetFlat() float64 {
+	if b.Flat > 0 {
+		return b.Flat
+	}
+	return 0.9 * b.Dia
+}
+
+// Return the distance from the centre to the furthest point of the bore.
+func (b Bore) GetMaxRadius() float64 {
+	switch b.Kind {
+	case DIN6885Bore, ANSIBore:
+		if _, t, err := b.GetKeyway(); err == nil {
+			return b.Dia/2 + t
+		}
+	case HexBore:
+		return b.Dia / 2 / math.Cos(math.Pi/6)
+	}
+	return b.Dia / 2
+}
+
+// Check that the bore can be made. It must fit inside a gear with root
+// diameter root.
+func (b Bore) Check(root float64) error {
+	if b.Dia <= 0 {
+		return nil
+	}
+	switch b.Kind {
+	case DIN6885Bore, ANSIBore:
+		if _, _, err := b.GetKeyway(); err != nil {
+			return err
+		}
+	case DFlatBore:
+		if f := b.GetFlat(); f <= b.Dia/2 || f >= b.Dia {
+			return fmt.Errorf("a D-flat of %.3f mm does not fit a %.3f mm "+
+				"bore", f, b.Dia)
+		}
+	}
+	if r := b.GetMaxRadius(); r >= root/2 {
+		return fmt.Errorf("the bore reaches a diameter of %.3f mm, past the "+
+			"root diameter of %.3f mm", 2*r, root)
+	}
+	return nil
+}
+
+// Return the outline of the bore, running anticlockwise about the origin.
+// There is no outline if the diameter is zero. A keyway the standard does
+// not cover is left out.
+func (b Bore) Outline() Outline {
+	if b.Dia <= 0 {
+		return nil
+	}
+	r := b.Dia / 2
+	// Cut the bore with a vertical line at x, on both sides of the x axis,
+	// and close it with the rest of the circle.
+	cut := func(x, y float64, side []Point) Outline {
+		p0, p1 := Point{x, -y}, Point{x, y}
+		var o Outline
+		pts := append(append([]Point{p0}, side...), p1)
+		for i := 1; i < len(pts); i++ {
+			o = append(o, Segment{Kind: Line, Points: []Point{pts[i-1], pts[i]}})
+		}
+		return append(o, Segment{Kind: Arc, Points: []Point{p1, p0}, Radius: r})
+	}
+	switch b.Kind {
+	case DIN6885Bore, ANSIBore:
+		w, t, err := b.GetKeyway()
+		if err != nil {
+			break
+		}
+		x := math.Sqrt(r*r - w*w/4)
+		return cut(x, w/2, []Point{{r + t, -w / 2}, {r + t, w / 2}})
+	case DFlatBore:
+		x := b.GetFlat() - r
+		return cut(x, math.Sqrt(r*r-x*x), nil)
+	case HexBore:
+		var o Outline
+		cr := r / math.Cos(math.Pi/6)
+		for i := 0; i < 6; i++ {
+			p0 := Point{cr, 0}.Rotate(math.Pi/6 + float64(i)*math.Pi/3)
+			p1 := Point{cr, 0}.Rotate(math.Pi/6 + float64(i+1)*math.Pi/3)
+			o = append(o, Segment{Kind: Line, Points: []Point{p0, p1}})
+		}
+		return o
+	}
+	return circleOutline(r, false)
+}
+
+// Return a short description of the bore.
+func (b Bore) String() string {
+	switch b.Kind {
+	case DIN6885Bore, ANSIBore:
+		if w, t, err := b.GetKeyway(); err == nil {
+			return fmt.Sprintf("%.3f, %s keyway %.3f wide %.3f deep", b.Dia,
+				b.Kind, w, t)
+		}
+	case DFlatBore:
+		return fmt.Sprintf("%.3f, D-flat %.3f across", b.Dia, b.GetFlat())
+	case HexBore:
+		return fmt.Sprintf("%.3f hex across flats", b.Dia)
+	}
+	return fmt.Sprintf("%.3f", b.Dia)
+}
@@ -0,0 +1,290 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The limits of a sound design, checked by Validate.
+const (
+	MinToothCount    = 5  // Fewest teeth on a gear
+	MinPressureAngle = 10 // Least pressure angle, in degrees
+	MaxPressureAngle = 35 // Greatest pressure angle, in degrees
+	MaxHelix         = 45 // Greatest helix angle, in degrees
+)
+
+// An error in one of the parameters of a gear or rack. Field is the name of
+// the field in the structure.
+type ParamError struct {
+	Field  string
+	Value  float64
+	Reason string
+}
+
+// Return a description of the error, naming the field and its value.
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("%s = %g, %s", e.Field, e.Value, e.Reason)
+}
+
+// An error in the shape worked out from the parameters, which together give
+// points that are not numbers even though each parameter is valid alone.
+type ShapeError struct {
+	Shape string // What was being worked out
+}
+
+// Return a description of the error.
+func (e *ShapeError) Error() string {
+	return fmt.Sprintf("the %s is not a number, the parameters do not make "+
+		"a gear", e.Shape)
+}
+
+// All the problems found by Validate, in the order of the fields of the
+// gear.
+type ValidationError struct {
+	Errors []error
+}
+
+// Return a description of every problem.
+func (e *ValidationError) Error() string {
+	s := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Return the problems, so that errors.As finds each of them.
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// Return an error if v is not a number.
+func checkNumber(field string, v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return &ParamError{Field: field, Value: v, Reason: "must be a number"}
+	}
+	return nil
+}
+
+// Return an error if v is not a number greater than 0.
+func checkPositive(field string, v float64) error {
+	if err := checkNumber(field, v); err != nil {
+		return err
+	}
+	if v <= 0 {
+		return &ParamError{Field: field, Value: v,
+			Reason: "must be greater than 0"}
+	}
+	return nil
+}
+
+// Check that the outline o is made of numbers.
+func checkOutline(shape string, o Outline) error {
+	for _, s := range o {
+		for _, p := range s.Points {
+			if math.IsNaN(p.X+p.Y) || math.IsInf(p.X+p.Y, 0) {
+				return &ShapeError{Shape: shape}
+			}
+		}
+	}
+	return nil
+}
+
+// Check that the gear can be drawn, and return an error for the first
+// problem found. This is a *ParamError for a parameter that is out of
+// range, or a *ShapeError if the involute or the outline cannot be worked
+// out.
+func (g Gear) Check() error {
+	if g.N < 1 {
+		return &ParamError{Field: "N", Value: float64(g.N),
+			Reason: "must be at least 1"}
+	}
+	if err := checkPositive("Pd", g.Pd); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name string
+		v    float64
+	}{{"A", g.A}, {"B", g.B}, {"X", g.X}, {"Rf", g.Rf}, {"Rim", g.Rim},
+		{"Helix", g.Helix}, {"Face", g.Face}, {"Kerf", g.Kerf}} {
+		if err := checkNumber(f.name, f.v); err != nil {
+			return err
+		}
+	}
+	// The flank of an internal gear is worked out from its space, which
+	// runs straight in to a tip inside the base circle, so only the outline
+	// is checked.
+	if !g.Internal {
+		if err := checkOutline("involute", Outline{{Kind: Involute,
+			Points: g.GetInvoluteFlank()}}); err != nil {
+			return err
+		}
+	}
+	return checkOutline("outline", g.Outline())
+}
+
+// Return an error if v is not a number, or is negative.
+func checkNotNegative(field string, v float64) error {
+	if err := checkNumber(field, v); err != nil {
+		return err
+	}
+	if v < 0 {
+		return &ParamError{Field: field, Value: v, Reason: "must not be negative"}
+	}
+	return nil
+}
+
+// Return an error if v is not a number from lo to hi.
+func checkRange(field string, v, lo, hi float64) error {
+	if err := checkNumber(field, v); err != nil {
+		return err
+	}
+	if v < lo || v > hi {
+		return &ParamError{Field: field, Value: v,
+			Reason: fmt.Sprintf("must be from %g to %g degrees", lo, hi)}
+	}
+	return nil
+}
+
+// Check the gear against the limits of a sound design as well as for what
+// Check finds, and return a *ValidationError holding every problem found.
+// The shape of the teeth, the bore and the web are only checked once the
+// parameters are sound.
+func (g Gear) Validate() error {
+	var errs []error
+	add := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	add(CheckTeeth(g.N))
+	add(checkPositive("Pd", g.Pd))
+	// A helical gear is cut to its pressure angle in the normal plane.
+	a := g.A
+	if g.Helix != 0 && checkNumber("Helix", g.Helix) == nil {
+		a = g.GetNormalPressureAngle()
+	}
+	add(checkRange("A", a, MinPressureAngle, MaxPressureAngle))
+	add(checkNotNegative("B", g.B))
+	add(checkNumber("X", g.X))
+	add(checkNotNegative("Rf", g.Rf))
+	add(checkNotNegative("Rim", g.Rim))
+	add(checkRange("Helix", g.Helix, 0, MaxHelix))
+	add(checkNotNegative("Face", g.Face))
+	add(checkNotNegative("Kerf", g.Kerf))
+	if len(errs) == 0 {
+		add(g.Check())
+	}
+	if len(errs) == 0 {
+		if g.Internal {
+			if g.Rim > 0 && g.Rim <= g.GetRootCircleDia() {
+				add(&ParamError{Field: "Rim", Value: g.Rim, Reason: fmt.Sprintf(
+					"must be larger than the root circle, %.3f",
+					g.GetRootCircleDia())})
+			}
+		} else {
+			if g.GetTipThickness() <= 0 {
+				add(&ParamError{Field: "X", Value: g.X,
+					Reason: "brings the teeth to a point below the tip"})
+			}
+			if err := g.Bore.Check(g.GetRootCircleDia()); err != nil {
+				add(fmt.Errorf("bore: %w", err))
+			}
+			if err := g.CheckWeb(); err != nil {
+				add(fmt.Errorf("web: %w", err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// Create a gear from its module, number of teeth, pressure angle and
+// backlash angle, as FromModule does, and validate it.
+func NewGear(m float64, n int, a, b float64) (Gear, error) {
+	g := FromModule(m, n, a, b)
+	return g, g.Validate()
+}
+
+// Create a helical gear from its normal module and pressure angle, as
+// FromNormal does, and validate it.
+func NewHelicalGear(mn float64, n int, an, b, helix float64) (Gear, error) {
+	g := FromNormal(mn, n, an, b, helix)
+	return g, g.Validate()
+}
+
+// Check that a gear of n teeth can be drawn, and return an error if it has
+// too few. The pitch diameter and much else are worked out from the teeth,
+// so they are worth checking first.
+func CheckTeeth(n int) error {
+	if n < MinToothCount {
+		return &ParamError{Field: "N", Value: float64(n),
+			Reason: fmt.Sprintf("must be at least %d", MinToothCount)}
+	}
+	return nil
+}
+
+// Check that the rack can be drawn, and return an error for the first
+// problem found, as for a gear.
+func (r Rack) Check() error {
+	if r.N < 1 {
+		return &ParamError{Field: "N", Value: float64(r.N),
+			Reason: "must be at least 1"}
+	}
+	if err := checkPositive("M", r.M); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name string
+		v    float64
+	}{{"A", r.A}, {"B", r.B}, {"Length", r.Length}, {"Back", r.Back},
+		{"Kerf", r.Kerf}} {
+		if err := checkNumber(f.name, f.v); err != nil {
+			return err
+		}
+	}
+	return checkOutline("outline", r.Outline())
+}
+
+// Check that both gears of the pair and the distance between them can be
+// drawn, and return an error for the first problem found.
+func (p Pair) Check() error {
+	if err := p.G1.Check(); err != nil {
+		return fmt.Errorf("first gear: %w", err)
+	}
+	if err := p.G2.Check(); err != nil {
+		return fmt.Errorf("second gear: %w", err)
+	}
+	return checkPositive("C", p.C)
+}
+
+// Check that the pinion and rack can be drawn, and return an error for the
+// first problem found.
+func (p RackPair) Check() error {
+	if err := p.G.Check(); err != nil {
+		return fmt.Errorf("pinion: %w", err)
+	}
+	if err := p.R.Check(); err != nil {
+		return fmt.Errorf("rack: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"math"
+)
+
+// The root fillet is the trochoid left behind by the rounded tip of a rack
+// cutter as it rolls along the pitch circle of the gear. The rack is
+// described in its own frame, with depth measured from the rack reference
+// line towards the centre of the gear, and lateral position measured from
+// the centre line of the rack tooth. Each rack tooth cuts one tooth space.
+
+// Number of points returned for each root fillet.
+const filletPoints = 24
+
+// A point on the gear profile. All dimensions are in mm, with the origin at
+// the centre of the gear.
+type Point struct {
+	X, Y float64
+}
+
+// Return the point rotated about the origin by angle ang (radians).
+func (p Point) Rotate(ang float64) Point {
+	s, c := math.Sincos(ang)
+	return Point{p.X*c - p.Y*s, p.X*s + p.Y*c}
+}
+
+// Return the distance of the point from the origin.
+func (p Point) Radius() float64 {
+	return math.Hypot(p.X, p.Y)
+}
+
+// Calculate and return the largest tip radius a rack cutter can have before
+// the rounding at the tip of the rack tooth meets its centre line.
+func (g Gear) GetMaxCutterTipRadius() float64 {
+	m := g.GetNormalModule()
+	a := g.GetNormalPressureAngle() * DegToRad
+	return (math.Pi*m/4 - (1+g.GetClearence())*m*math.Tan(a)) *
+		math.Cos(a) / (1 - math.Sin(a))
+}
+
+// Calculate and return the tip radius of the rack cutter in mm. Rf is given
+// as a multiple of the module and is limited to what will fit on the rack.
+func (g Gear) GetCutterTipRadius() float64 {
+	return math.Max(0, math.Min(g.Rf*g.GetNormalModule(),
+		g.GetMaxCutterTipRadius()))
+}
+
+// Return the depth and lateral position of the centre of the cutter tip
+// rounding, along with its radius. A helical gear is cut by a rack that is
+// longer and has steeper flanks in the transverse plane, but is no deeper.
+func (g Gear) cutterTip() (hc, sc, rho float64) {
+	m := g.GetModule()
+	a := g.A * DegToRad
+	rho = g.GetCutterTipRadius()
+	hc = (1+g.GetClearence())*g.GetNormalModule() - rho
+	sc = math.Pi*m/4 - hc*math.Tan(a) - rho/math.Cos(a)
+	return hc, sc, rho
+}
+
+// Return the depth below the pitch circle at which the straight flank of the
+// rack meets the tip rounding. The rack generates involute down to here.
+func (g Gear) cutterFlankDepth() float64 {
+	hc, _, rho := g.cutterTip()
+	return hc + rho*math.Sin(g.A*DegToRad) - g.X*g.GetNormalModule()
+}
+
+// Report whether the straight flank of the rack reaches below the point
+// where the line of action touches the base circle. If it does, the tip of
+// the cutter cuts away the bottom of the involute. Internal gears are cut
+// with a pinion type cutter, and are not undercut by it.
+func (g Gear) IsUndercut() bool {
+	return !g.Internal && g.cutterFlankDepth() > g.Pd/2*math.Pow(math.Sin(g.A*DegToRad), 2)
+}
+
+// Calculate and return the fewest teeth that can be cut without undercut,
+// for the pressure angle, profile shift and cutter of this gear.
+func (g Gear) GetMinTeeth() int {
+	zmin := 2 * g.cutterFlankDepth() / g.GetModule() /
+		math.Pow(math.Sin(g.A*DegToRad), 2)
+	return int(math.Max(1, math.Ceil(zmin-1e-9)))
+}
+
+// Return the angle between the tooth centre line and the involute flank at
+// radius r.
+func (g Gear) involuteHalfAngle(r float64) float64 {
+	ar := math.Acos(g.GetBaseCircleDia() / 2 / r)
+	return g.GetAngularToothThickness()*DegToRad/2 +
+		involute(g.A*DegToRad) - involute(ar)
+}
+
+// Return the point on the root fillet when the gear has rolled through angle
+// phi. The point is given on the lower flank of the tooth centred on the
+// positive x axis.
+func (g Gear) filletPoint(phi float64) Point {
+	hc, sc, rho := g.cutterTip()
+	r := g.Pd / 2
+	d := hc - g.X*g.GetNormalModule() // Depth of the tip centre below pitch circle
+	x := r - d
+	y := sc + r*phi
+	s, c := math.Sincos(phi)
+	centre := Point{x*c + y*s, -x*s + y*c}
+	// The cut surface lies one tip radius from the path of the centre.
+	dx := -x*s + y*c + r*s
+	dy := -x*c - y*s + r*c
+	l := math.Hypot(dx, dy)
+	if l == 0 {
+		dx, dy, l = -centre.Y, centre.X, centre.Radius()
+	}
+	nx, ny := -dy/l, dx/l
+	if d < 0 {
+		nx, ny = -nx, -ny
+	}
+	p := Point{centre.X + rho*nx, centre.Y + rho*ny}
+	return p.Rotate(-math.Pi / float64(g.N))
+}
+
+// Return the range of roll angles over which the fillet forms the flank. It
+// starts at the root circle and ends where the involute takes over, either
+// at the form circle or where the fillet crosses an undercut involute.
+func (g Gear) filletRange() (phi0, phi1 float64) {
+	_, sc, _ := g.cutterTip()
+	phi0 = -sc / (g.Pd / 2)
+	br := g.GetBaseCircleDia() / 2
+	or := g.GetTipDia() / 2
+	undercut := g.IsUndercut()
+	var fr float64
+	if !undercut {
+		a := g.A * DegToRad
+		fr = math.Hypot(br, g.Pd/2*math.Sin(a)-g.cutterFlankDepth()/math.Sin(a))
+	}
+	// done is positive once the fillet has reached the involute.
+	done := func(phi float64) float64 {
+		p := g.filletPoint(phi)
+		r := p.Radius()
+		if r >= or {
+			return r - or
+		}
+		if !undercut {
+			return r - fr
+		}
+		if r < br {
+			return -1
+		}
+		return -math.Atan2(p.Y, p.X) - g.involuteHalfAngle(r)
+	}
+	step := 0.001
+	prev := phi0
+	for phi := phi0 + step; phi < phi0+math.Pi; phi += step {
+		if done(phi) >= 0 {
+			lo, hi := prev, phi
+			for i := 0; i < 50; i++ {
+				mid := (lo + hi) / 2
+				if done(mid) >= 0 {
+					hi = mid
+				} else {
+					lo = mid
+				}
+			}
+			return phi0, hi
+		}
+		prev = phi
+	}
+	return phi0, prev
+}
+
+// Calculate and return the diameter at which the involute flank starts. Below
+// this the flank is formed by the root fillet. The involute of an internal
+// gear starts at its tip, unless that is inside the base circle.
+func (g Gear) GetFormCircleDia() float64 {
+	if g.Internal {
+		return math.Max(g.GetTipDia(), g.GetBaseCircleDia())
+	}
+	_, phi1 := g.filletRange()
+	return 2 * g.filletPoint(phi1).Radius()
+}
+
+// Calculate and return the width of a tooth space where the involute flanks
+// end, at the form circle of an external gear or the root of an internal
+// one. A cutter any wider cannot reach the bottom of the flanks.
+func (g Gear) GetRootGap() float64 {
+	if g.Internal {
+		r := g.GetRootCircleDia() / 2
+		space := g.GetCircularPitch() - g.GetToothThickness()
+		ar := math.Acos(g.GetBaseCircleDia() / 2 / r)
+		half := space/g.Pd + involute(g.A*DegToRad) - involute(ar)
+		return 2 * r * math.Sin(half)
+	}
+	r := g.GetFormCircleDia() / 2
+	half := math.Pi/float64(g.N) - g.involuteHalfAngle(r)
+	return 2 * r * math.Sin(half)
+}
+
+// Return the root fillet of the lower flank of the tooth centred on the
+// positive x axis, running from the root circle out to the form circle.
+func (g Gear) GetRootFillet() []Point {
+	phi0, phi1 := g.filletRange()
+	pts := make([]Point, filletPoints)
+	for i := range pts {
+		phi := phi0 + (phi1-phi0)*float64(i)/float64(filletPoints-1)
+		pts[i] = g.filletPoint(phi)
+	}
+	return pts
+}
@@ -35,6 +35,44 @@ type Gear struct {
 	N  int     // Number of teeth
 	A  float64 // pressure angle
 	B  float64 // backlash angle
+	X  float64 // profile shift coefficient
+	Rf float64 // rack cutter tip radius coefficient
+	// An internal (ring) gear has its teeth on the inside of a rim.
+	Internal bool
+	Rim      float64 // rim diameter of an internal gear, 0 for a default
+	Bore     Bore    // centre bore of an external gear, zero for none
+	Web      Web     // lightening of an external gear, zero for none
+	// A helical gear has its teeth at Helix degrees to the axis. Pd and A
+	// are then in the transverse plane, and X in the normal plane.
+	Helix float64
+	Hand  Hand
+	Face  float64 // face width
+	// The contours to cut are grown by half of Kerf all round, so a cutter
+	// that burns away Kerf mm along the line leaves the gear as designed.
+	Kerf float64
+}
+
+// Create a gear from its metric module, number of teeth, pressure angle and
+// backlash angle. The pitch diameter is derived as module * teeth.
+func FromModule(m float64, n int, a float64, b float64) Gear {
+	return Gear{
+		Pd: m * float64(n),
+		N:  n,
+		A:  a,
+		B:  b,
+	}
+}
+
+// Calculate and return the metric module. This is the reciprocal of the
+// diametric pitch, in mm per tooth.
+func (g Gear) GetModule() float64 {
+	return g.Pd / float64(g.N)
+}
+
+// Calculate and return the circular pitch, the distance between adjacent
+// teeth measured along the pitch circle.
+func (g Gear) GetCircularPitch() float64 {
+	return math.Pi * g.GetModule()
 }
 
 // Calculate and return the diametric pitch
@@ -44,22 +82,58 @@ func (g Gear) GetDiametricPitch() float64 {
 
 // Calculate and return clearence. This needs to be checked.
 func (g Gear) GetClearence() float64 {
-	return g.A / 100.0
+	return g.GetNormalPressureAngle() / 100.0
+}
+
+// Return -1 for an internal gear and 1 for an external one. Most pair
+// calculations hold for internal gears if the tooth count, pitch diameter
+// and profile shift of the internal gear are taken as negative.
+func (g Gear) sign() float64 {
+	if g.Internal {
+		return -1
+	}
+	return 1
 }
 
-// Calculate and return the gear addendum
+// Return the profile shift coefficient as it acts on the tooth. Shifting
+// an internal gear outwards makes its teeth shorter and thinner.
+func (g Gear) shift() float64 {
+	return g.sign() * g.X
+}
+
+// Calculate and return the gear addendum, lengthened by any profile shift.
+// The height of the teeth of a helical gear is set by its normal module.
 func (g Gear) GetAddendum() float64 {
-	return 1.0 / g.GetDiametricPitch()
+	return (1.0 + g.shift()) * g.GetNormalModule()
 }
 
-// Calculate and return the gear dedendum
+// Calculate and return the gear dedendum, shortened by any profile shift.
 func (g Gear) GetDedendum() float64 {
-	return (1.0 + g.GetClearence()) / g.GetDiametricPitch()
+	return (1.0 + g.GetClearence() - g.shift()) * g.GetNormalModule()
+}
+
+// Calculate and return the tip diameter. The teeth of an internal gear
+// point inwards, so its tip circle is inside the pitch circle.
+func (g Gear) GetTipDia() float64 {
+	return g.Pd + 2*g.sign()*g.GetAddendum()
 }
 
-// Calculate and return the outside diameter
+// Calculate and return the outside diameter. For an internal gear this is
+// the diameter of the rim.
 func (g Gear) GetOutsideDia() float64 {
-	return (float64(g.N) + 2.0) / g.GetDiametricPitch()
+	if g.Internal {
+		return g.GetRimDia()
+	}
+	return g.GetTipDia()
+}
+
+// Calculate and return the rim diameter of an internal gear. Unless Rim is
+// given, the rim is five modules deep below the root circle.
+func (g Gear) GetRimDia() float64 {
+	if g.Rim > 0 {
+		return g.Rim
+	}
+	return g.GetRootCircleDia() + 10*g.GetModule()
 }
 
 // Calculate and return the base diameter
@@ -67,19 +141,33 @@ func (g Gear) GetBaseCircleDia() float64 {
 	return g.Pd * math.Cos(g.A*DegToRad)
 }
 
+// Calculate and return the tooth thickness measured along the pitch circle.
+// A positive profile shift thickens the tooth of an external gear.
+func (g Gear) GetToothThickness() float64 {
+	return g.GetModule()*math.Pi/2 +
+		2*g.shift()*g.GetNormalModule()*math.Tan(g.A*DegToRad)
+}
+
 // Calculate and return the tooth chordal thickness
 func (g Gear) GetChordalToothThickness() float64 {
-	return g.Pd * math.Sin((90*DegToRad)/float64(g.N))
+	return g.Pd * math.Sin(g.GetAngularToothThickness()*DegToRad/2)
 }
 
 // Calculate and return the tooth angular thickness
 func (g Gear) GetAngularToothThickness() float64 {
-	return 360 / float64(g.N) / 2
+	return g.GetToothThickness() / (g.Pd / 2) * RadToDeg
+}
+
+// Calculate and return the tooth thickness measured along the tip circle of
+// an external gear. It is zero or less if the teeth come to a point below
+// the tip.
+func (g Gear) GetTipThickness() float64 {
+	return g.GetTipDia() * g.involuteHalfAngle(g.GetTipDia()/2)
 }
 
 // Calculate and return the gear root circle diameter
 func (g Gear) GetRootCircleDia() float64 {
-	return g.Pd - (2 * g.GetDedendum())
+	return g.Pd - (2 * g.sign() * g.GetDedendum())
 }
 
 // Return the alpha angle from the root to the point the involute crosses the
@@ -89,22 +177,112 @@ func (g Gear) GetAlphaAngle() float64 {
 		g.GetBaseCircleDia()*RadToDeg - g.A
 }
 
+// Calculate the involute function of angle a (radians).
+func involute(a float64) float64 {
+	return math.Tan(a) - a
+}
+
+// Find the angle (radians) whose involute function is v. Newton's method
+// converges in a handful of steps for any angle a gear is likely to use.
+func inverseInvolute(v float64) float64 {
+	a := math.Cbrt(3 * v) // Good first guess for small angles
+	for i := 0; i < 50; i++ {
+		t := math.Tan(a)
+		step := (t - a - v) / (t * t)
+		a -= step
+		if math.Abs(step) < 1e-15 {
+			break
+		}
+	}
+	return a
+}
+
+// Calculate and return the operating pressure angle (degrees) of a meshing
+// pair. Unless the pair has profile shift this is the same as the pressure
+// angle of the gears.
+func OperatingPressureAngle(g1, g2 Gear) float64 {
+	a := g1.A * DegToRad
+	an := g1.GetNormalPressureAngle() * DegToRad
+	v := involute(a) + 2*math.Tan(an)*(g1.shift()+g2.shift())/
+		(g1.sign()*float64(g1.N)+g2.sign()*float64(g2.N))
+	return inverseInvolute(v) * RadToDeg
+}
+
+// Calculate and return the centre distance at which a pair of gears mesh
+// without backlash. For unshifted gears this is the mean of the pitch
+// diameters, or half their difference if one is an internal gear.
+func WorkingCentreDistance(g1, g2 Gear) float64 {
+	aw := OperatingPressureAngle(g1, g2) * DegToRad
+	return referenceCentreDistance(g1, g2) * math.Cos(g1.A*DegToRad) /
+		math.Cos(aw)
+}
+
+// Return the centre distance of a pair of unshifted gears.
+func referenceCentreDistance(g1, g2 Gear) float64 {
+	return math.Abs(g1.sign()*g1.Pd+g2.sign()*g2.Pd) / 2
+}
+
+// Calculate the total profile shift coefficient (x1 + x2) required for a
+// pair of gears to mesh at centre distance c. Only the module, tooth counts
+// and pressure angle of the gears are used. If g2 is an internal gear the
+// result is x2 - x1 instead.
+func ShiftForCentres(g1, g2 Gear, c float64) float64 {
+	a := g1.A * DegToRad
+	an := g1.GetNormalPressureAngle() * DegToRad
+	aw := math.Acos(referenceCentreDistance(g1, g2) * math.Cos(a) / c)
+	return (involute(aw) - involute(a)) *
+		math.Abs(g1.sign()*float64(g1.N)+g2.sign()*float64(g2.N)) /
+		(2 * math.Tan(an))
+}
+
 // Spit out a load of text that describes this gear.
 func (g Gear) String() string {
 	var retval string
 	retval += fmt.Sprintf("Pitch Diameter:          %.3f\n", g.Pd)
 	retval += fmt.Sprintf("Outside Diameter:        %.3f\n", g.GetOutsideDia())
+	retval += fmt.Sprintf("Tip Diameter:            %.3f\n", g.GetTipDia())
+	retval += fmt.Sprintf("Module:                  %.3f\n", g.GetModule())
 	retval += fmt.Sprintf("Diametric Pitch:         %.3f\n",
 		g.GetDiametricPitch())
+	retval += fmt.Sprintf("Circular Pitch:          %.3f\n",
+		g.GetCircularPitch())
+	retval += fmt.Sprintf("Profile Shift:           %.3f\n", g.X)
 	retval += fmt.Sprintf("Clearance:               %.3f\n", g.GetClearence())
 	retval += fmt.Sprintf("Addendum:                %.3f\n", g.GetAddendum())
 	retval += fmt.Sprintf("Dedendum:                %.3f\n", g.GetDedendum())
 	retval += fmt.Sprintf("Base Circle Diameter:    %.3f\n", g.GetBaseCircleDia())
 	retval += fmt.Sprintf("Root Circle Diameter:    %.3f\n", g.GetRootCircleDia())
+	retval += fmt.Sprintf("Form Circle Diameter:    %.3f\n", g.GetFormCircleDia())
+	retval += fmt.Sprintf("Cutter Tip Radius:       %.3f\n",
+		g.GetCutterTipRadius())
+	retval += fmt.Sprintf("Tooth Thickness:         %.3f\n",
+		g.GetToothThickness())
 	retval += fmt.Sprintf("Chordal Tooth Thickness: %.3f\n",
 		g.GetChordalToothThickness())
 	retval += fmt.Sprintf("Angular Tooth Thickness: %.3f\n",
 		g.GetAngularToothThickness())
 	retval += fmt.Sprintf("Alpha Angle:             %.3f\n", g.GetAlphaAngle())
+	if g.Helix != 0 {
+		retval += fmt.Sprintf("Helix Angle:             %.3f %s hand\n",
+			g.Helix, g.Hand)
+		retval += fmt.Sprintf("Normal Module:           %.3f\n",
+			g.GetNormalModule())
+		retval += fmt.Sprintf("Normal Press. Angle:     %.3f\n",
+			g.GetNormalPressureAngle())
+		retval += fmt.Sprintf("Lead:                    %.3f\n", g.GetLead())
+	}
+	if g.Bore.Dia > 0 && !g.Internal {
+		retval += fmt.Sprintf("Bore:                    %s\n", g.Bore)
+	}
+	switch {
+	case g.Internal:
+	case g.Web.Kind == HoleWeb:
+		retval += fmt.Sprintf("Web:                     %d holes %.3f on "+
+			"%.3f\n", g.Web.N, g.GetWebSize(), g.GetHolePCD())
+	case g.Web.Kind == SpokeWeb:
+		retval += fmt.Sprintf("Web:                     %d spokes %.3f wide, "+
+			"hub %.3f, rim %.3f\n", g.Web.N, g.GetWebSize(), g.GetHubDia(),
+			g.GetWebRimDia())
+	}
 	return retval
 }
@@ -17,8 +17,12 @@
 package gear
 
 import (
+	"errors"
+	"fmt"
 	"math"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Round(f float64) float64 {
@@ -52,7 +56,7 @@ func TestDiametricPitch(t *testing.T) {
 		}
 		got := g.GetDiametricPitch()
 		if got != c.want {
-			t.Errorf("GetDiametricPitch(Pd %f, N %f, A %f) == %f, want %f",
+			t.Errorf("GetDiametricPitch(Pd %f, N %d, A %f) == %f, want %f",
 				c.inPd, c.inN, c.inA, got, c.want)
 		}
 	}
@@ -71,7 +75,7 @@ func TestClearence(t *testing.T) {
 		}
 		got := g.GetClearence()
 		if got != c.want {
-			t.Errorf("GetClearence(Pd %f, N %f, A %f) == %f, want %f",
+			t.Errorf("GetClearence(Pd %f, N %d, A %f) == %f, want %f",
 				c.inPd, c.inN, c.inA, got, c.want)
 		}
 	}
@@ -90,7 +94,7 @@ func TestAddendum(t *testing.T) {
 		}
 		got := g.GetAddendum()
 		if got != c.want {
-			t.Errorf("GetAddendum(Pd %f, N %f, A %f) == %f, want %f",
+			t.Errorf("GetAddendum(Pd %f, N %d, A %f) == %f, want %f",
 				c.inPd, c.inN, c.inA, got, c.want)
 		}
 	}
@@ -109,7 +113,7 @@ func TestDedendum(t *testing.T) {
 		}
 		got := g.GetDedendum()
 		if got != c.want {
-			t.Errorf("GetDedendum(Pd %f, N %f, A %f) == %f, want %f",
+			t.Errorf("GetDedendum(Pd %f, N %d, A %f) == %f, want %f",
 				c.inPd, c.inN, c.inA, got, c.want)
 		}
 	}
@@ -128,7 +132,7 @@ func TestOutsideDia(t *testing.T) {
 		}
 		got := g.GetOutsideDia()
 		if got != c.want {
-			t.Errorf("GetOutsideDia(Pd %f, N %f, A %f) == %f, want %f",
+			t.Errorf("GetOutsideDia(Pd %f, N %d, A %f) == %f, want %f",
 				c.inPd, c.inN, c.inA, got, c.want)
 		}
 	}
@@ -147,7 +151,7 @@ func TestBaseCircleDia(t *testing.T) {
 		}
 		got := RoundPlus(g.GetBaseCircleDia(), 3)
 		if got != c.want {
-			t.Errorf("GetBaseCircleDia(Pd %.3f, N %.3f, A %.3f) == %.3f, want %.3f",
+			t.Errorf("GetBaseCircleDia(Pd %.3f, N %d, A %.3f) == %.3f, want %.3f",
 				c.inPd, c.inN, c.inA, got, c.want)
 		}
 	}
@@ -166,7 +170,7 @@ func TestChordalToothThickness(t *testing.T) {
 		}
 		got := RoundPlus(g.GetChordalToothThickness(), 3)
 		if got != c.want {
-			t.Errorf("GetChordalToothThickness(Pd %.3f, N %.3f, A %.3f) == %.3f, want %.3f",
+			t.Errorf("GetChordalToothThickness(Pd %.3f, N %d, A %.3f) == %.3f, want %.3f",
 				c.inPd, c.inN, c.inA, got, c.want)
 		}
 	}
@@ -185,7 +189,7 @@ func TestAngularToothThickness(t *testing.T) {
 		}
 		got := RoundPlus(g.GetAngularToothThickness(), 3)
 		if got != c.want {
-			t.Errorf("GetAngularToothThickness(Pd %.3f, N %.3f, A %.3f) == %.3f, want %.3f",
+			t.Errorf("GetAngularToothThickness(Pd %.3f, N %d, A %.3f) == %.3f, want %.3f",
 				c.inPd, c.inN, c.inA, got, c.want)
 		}
 	}
@@ -204,7 +208,7 @@ func TestRootCircleDia(t *testing.T) {
 		}
 		got := RoundPlus(g.GetRootCircleDia(), 3)
 		if got != c.want {
-			t.Errorf("GetRootCircleDiameter(Pd %.3f, N %.3f, A %.3f) == %.3f, want %.3f",
+			t.Errorf("GetRootCircleDiameter(Pd %.3f, N %d, A %.3f) == %.3f, want %.3f",
 				c.inPd, c.inN, c.inA, got, c.want)
 		}
 	}
@@ -223,8 +227,1458 @@ func TestAlphaAngle(t *testing.T) {
 		}
 		got := RoundPlus(g.GetAlphaAngle(), 3)
 		if got != c.want {
-			t.Errorf("GetAlphaAngle(Pd %.3f, N %.3f, A %.3f) == %.3f, want %.3f",
+			t.Errorf("GetAlphaAngle(Pd %.3f, N %d, A %.3f) == %.3f, want %.3f",
 				c.inPd, c.inN, c.inA, got, c.want)
 		}
 	}
 }
+
+func TestModule(t *testing.T) {
+	cases := []testCase{
+		{100, 10, 30, 10},
+		{200, 8, 25, 25},
+		{34.5, 23, 20, 1.5},
+	}
+	for _, c := range cases {
+		g := Gear{
+			Pd: c.inPd,
+			N:  c.inN,
+			A:  c.inA,
+		}
+		got := RoundPlus(g.GetModule(), 3)
+		if got != c.want {
+			t.Errorf("GetModule(Pd %.3f, N %d, A %.3f) == %.3f, want %.3f",
+				c.inPd, c.inN, c.inA, got, c.want)
+		}
+	}
+}
+
+func TestFromModule(t *testing.T) {
+	cases := []struct {
+		inM    float64
+		inN    int
+		inA    float64
+		wantPd float64
+		wantOd float64
+		wantRd float64
+	}{
+		{1.5, 23, 20, 34.5, 37.5, 30.9},
+		{2, 7, 25, 14, 18, 9},
+		{0.5, 100, 20, 50, 51, 48.8},
+	}
+	for _, c := range cases {
+		g := FromModule(c.inM, c.inN, c.inA, 0)
+		gotPd := RoundPlus(g.Pd, 3)
+		gotOd := RoundPlus(g.GetOutsideDia(), 3)
+		gotRd := RoundPlus(g.GetRootCircleDia(), 3)
+		if gotPd != c.wantPd || gotOd != c.wantOd || gotRd != c.wantRd {
+			t.Errorf("FromModule(m %.3f, N %d, A %.3f) Pd,Od,Rd == %.3f,%.3f,%.3f want %.3f,%.3f,%.3f",
+				c.inM, c.inN, c.inA, gotPd, gotOd, gotRd, c.wantPd, c.wantOd, c.wantRd)
+		}
+		if RoundPlus(g.GetModule(), 9) != c.inM {
+			t.Errorf("FromModule(m %.3f, N %d, A %.3f).GetModule() == %.9f",
+				c.inM, c.inN, c.inA, g.GetModule())
+		}
+	}
+}
+
+// Profile shifted cases. Pitch diameter, number of teeth, pressure angle and
+// shift coefficient, along with the expected result.
+type shiftCase struct {
+	inPd float64
+	inN  int
+	inA  float64
+	inX  float64
+	want float64
+}
+
+func TestShiftedAddendum(t *testing.T) {
+	cases := []shiftCase{
+		{20, 10, 20, 0.5, 3},
+		{20, 10, 20, -0.25, 1.5},
+		{20, 10, 20, 0, 2},
+	}
+	for _, c := range cases {
+		g := Gear{Pd: c.inPd, N: c.inN, A: c.inA, X: c.inX}
+		got := RoundPlus(g.GetAddendum(), 3)
+		if got != c.want {
+			t.Errorf("GetAddendum(Pd %.3f, N %d, A %.3f, X %.3f) == %.3f, want %.3f",
+				c.inPd, c.inN, c.inA, c.inX, got, c.want)
+		}
+	}
+}
+
+func TestShiftedRootCircleDia(t *testing.T) {
+	cases := []shiftCase{
+		{20, 10, 20, 0.5, 17.2},
+		{20, 10, 20, -0.25, 14.2},
+		{20, 10, 20, 0, 15.2},
+	}
+	for _, c := range cases {
+		g := Gear{Pd: c.inPd, N: c.inN, A: c.inA, X: c.inX}
+		got := RoundPlus(g.GetRootCircleDia(), 3)
+		if got != c.want {
+			t.Errorf("GetRootCircleDia(Pd %.3f, N %d, A %.3f, X %.3f) == %.3f, want %.3f",
+				c.inPd, c.inN, c.inA, c.inX, got, c.want)
+		}
+	}
+}
+
+func TestShiftedToothThickness(t *testing.T) {
+	cases := []shiftCase{
+		{20, 10, 20, 0, 3.142},
+		{20, 10, 20, 0.5, 3.870},
+		{36, 12, 20, 0.6, 6.023},
+	}
+	for _, c := range cases {
+		g := Gear{Pd: c.inPd, N: c.inN, A: c.inA, X: c.inX}
+		got := RoundPlus(g.GetToothThickness(), 3)
+		if got != c.want {
+			t.Errorf("GetToothThickness(Pd %.3f, N %d, A %.3f, X %.3f) == %.3f, want %.3f",
+				c.inPd, c.inN, c.inA, c.inX, got, c.want)
+		}
+	}
+}
+
+func TestOperatingPressureAngle(t *testing.T) {
+	cases := []struct {
+		g1, g2 Gear
+		wantA  float64
+		wantC  float64
+	}{
+		{Gear{Pd: 36, N: 12, A: 20, X: 0.6}, Gear{Pd: 72, N: 24, A: 20, X: 0.36},
+			26.089, 56.500},
+		{Gear{Pd: 20, N: 10, A: 25}, Gear{Pd: 40, N: 20, A: 25}, 25, 30},
+		{Gear{Pd: 20, N: 10, A: 20, X: 0.3}, Gear{Pd: 40, N: 20, A: 20, X: -0.3},
+			20, 30},
+	}
+	for _, c := range cases {
+		gotA := RoundPlus(OperatingPressureAngle(c.g1, c.g2), 3)
+		gotC := RoundPlus(WorkingCentreDistance(c.g1, c.g2), 3)
+		if gotA != c.wantA || gotC != c.wantC {
+			t.Errorf("OperatingPressureAngle, WorkingCentreDistance(X %.3f, X %.3f) == %.3f, %.3f want %.3f, %.3f",
+				c.g1.X, c.g2.X, gotA, gotC, c.wantA, c.wantC)
+		}
+		gotX := RoundPlus(ShiftForCentres(c.g1, c.g2, WorkingCentreDistance(c.g1, c.g2)), 6)
+		if gotX != RoundPlus(c.g1.X+c.g2.X, 6) {
+			t.Errorf("ShiftForCentres(X %.3f, X %.3f) == %.6f, want %.6f",
+				c.g1.X, c.g2.X, gotX, c.g1.X+c.g2.X)
+		}
+	}
+}
+
+func TestCutterTipRadius(t *testing.T) {
+	cases := []struct {
+		inM, inA, inRf float64
+		want           float64
+	}{
+		{1, 20, 0.38, 0.380},
+		{2, 20, 0.38, 0.760},
+		{1, 20, 1.00, 0.498},
+		{1, 25, 0.38, 0.318},
+		{1, 25, 0, 0},
+	}
+	for _, c := range cases {
+		g := FromModule(c.inM, 20, c.inA, 0)
+		g.Rf = c.inRf
+		got := RoundPlus(g.GetCutterTipRadius(), 3)
+		if got != c.want {
+			t.Errorf("GetCutterTipRadius(M %.3f, A %.3f, Rf %.3f) == %.3f, want %.3f",
+				c.inM, c.inA, c.inRf, got, c.want)
+		}
+	}
+}
+
+func TestFormCircleDia(t *testing.T) {
+	cases := []struct {
+		inN       int
+		inA, inRf float64
+		inX       float64
+		want      float64
+	}{
+		// Clear of undercut, the form circle is where the straight flank
+		// of the rack stops generating the involute.
+		{30, 20, 0.38, 0, 28.581},
+		{30, 20, 0, 0, 28.377},
+		{12, 20, 0.38, 0.6, 11.463},
+		// Undercut teeth have the involute cut away above the base circle.
+		{10, 20, 0.38, 0, 9.440},
+		{7, 25, 0.38, 0, 6.404},
+	}
+	for _, c := range cases {
+		g := FromModule(1, c.inN, c.inA, 0)
+		g.Rf = c.inRf
+		g.X = c.inX
+		got := RoundPlus(g.GetFormCircleDia(), 3)
+		if got != c.want {
+			t.Errorf("GetFormCircleDia(N %d, A %.3f, Rf %.3f, X %.3f) == %.3f, want %.3f",
+				c.inN, c.inA, c.inRf, c.inX, got, c.want)
+		}
+		if got < RoundPlus(g.GetBaseCircleDia(), 3) {
+			t.Errorf("GetFormCircleDia(N %d, A %.3f, Rf %.3f, X %.3f) == %.3f, inside base circle %.3f",
+				c.inN, c.inA, c.inRf, c.inX, got, g.GetBaseCircleDia())
+		}
+	}
+}
+
+func TestRootFillet(t *testing.T) {
+	for _, n := range []int{7, 10, 17, 30, 100} {
+		g := FromModule(1, n, 20, 0)
+		g.Rf = 0.38
+		f := g.GetRootFillet()
+		first, last := f[0], f[len(f)-1]
+		if RoundPlus(first.Radius()*2, 6) != RoundPlus(g.GetRootCircleDia(), 6) {
+			t.Errorf("GetRootFillet(N %d) starts at dia %.6f, want root dia %.6f",
+				n, first.Radius()*2, g.GetRootCircleDia())
+		}
+		if RoundPlus(last.Radius()*2, 6) != RoundPlus(g.GetFormCircleDia(), 6) {
+			t.Errorf("GetRootFillet(N %d) ends at dia %.6f, want form dia %.6f",
+				n, last.Radius()*2, g.GetFormCircleDia())
+		}
+		// The fillet belongs to the lower flank, inside the tooth space.
+		for _, p := range f {
+			ang := math.Atan2(p.Y, p.X)
+			if ang > 0 || ang < -math.Pi/float64(n) {
+				t.Errorf("GetRootFillet(N %d) point %v outside tooth space", n, p)
+			}
+		}
+	}
+}
+
+func TestMinTeeth(t *testing.T) {
+	cases := []struct {
+		inA, inRf, inX float64
+		want           int
+	}{
+		{20, 0.38, 0, 17},
+		{20, 0, 0, 21},
+		{25, 0.38, 0, 12},
+		{20, 0.38, 0.5, 8},
+	}
+	for _, c := range cases {
+		g := FromModule(1, 20, c.inA, 0)
+		g.Rf = c.inRf
+		g.X = c.inX
+		got := g.GetMinTeeth()
+		if got != c.want {
+			t.Errorf("GetMinTeeth(A %.3f, Rf %.3f, X %.3f) == %d, want %d",
+				c.inA, c.inRf, c.inX, got, c.want)
+		}
+		g.N = got
+		if g.IsUndercut() {
+			t.Errorf("IsUndercut(A %.3f, Rf %.3f, X %.3f, N %d) == true, want false",
+				c.inA, c.inRf, c.inX, got)
+		}
+		g.N = got - 1
+		if !g.IsUndercut() {
+			t.Errorf("IsUndercut(A %.3f, Rf %.3f, X %.3f, N %d) == false, want true",
+				c.inA, c.inRf, c.inX, got-1)
+		}
+	}
+}
+
+func TestCheckInterference(t *testing.T) {
+	cases := []struct {
+		n1, n2         int
+		x1, x2         float64
+		wantUndercut   [2]bool
+		wantInterferes [2]bool
+	}{
+		{17, 23, 0, 0, [2]bool{false, false}, [2]bool{false, false}},
+		{7, 23, 0, 0, [2]bool{true, false}, [2]bool{true, false}},
+		{7, 23, 0.6, 0, [2]bool{false, false}, [2]bool{false, false}},
+		{30, 60, 0, 0, [2]bool{false, false}, [2]bool{false, false}},
+	}
+	for _, c := range cases {
+		g1 := FromModule(1, c.n1, 20, 0)
+		g2 := FromModule(1, c.n2, 20, 0)
+		g1.Rf, g2.Rf = 0.38, 0.38
+		g1.X, g2.X = c.x1, c.x2
+		i1, i2 := CheckInterference(g1, g2)
+		gotUndercut := [2]bool{i1.Undercut, i2.Undercut}
+		gotInterferes := [2]bool{i1.Interference, i2.Interference}
+		if gotUndercut != c.wantUndercut || gotInterferes != c.wantInterferes {
+			t.Errorf("CheckInterference(N %d, N %d, X %.3f, X %.3f) undercut %v, interference %v, want %v, %v",
+				c.n1, c.n2, c.x1, c.x2, gotUndercut, gotInterferes,
+				c.wantUndercut, c.wantInterferes)
+		}
+		for _, i := range []Interference{i1, i2} {
+			if i.Interference && i.Depth <= 0 {
+				t.Errorf("CheckInterference(N %d, N %d) depth %.3f, want > 0",
+					c.n1, c.n2, i.Depth)
+			}
+			if !i.Interference && i.ContactDia < i.FormDia {
+				t.Errorf("CheckInterference(N %d, N %d) contact dia %.3f inside form dia %.3f",
+					c.n1, c.n2, i.ContactDia, i.FormDia)
+			}
+		}
+	}
+}
+
+func TestContactRatio(t *testing.T) {
+	cases := []struct {
+		n1, n2      int
+		inA, x1, x2 float64
+		wantPath    float64
+		wantRatio   float64
+	}{
+		{20, 40, 20, 0, 0, 4.827, 1.635},
+		{17, 23, 25, 0, 0, 4.008, 1.408},
+		{12, 24, 20, 0.6, 0.36, 3.979, 1.348},
+	}
+	for _, c := range cases {
+		g1 := FromModule(1, c.n1, c.inA, 0)
+		g2 := FromModule(1, c.n2, c.inA, 0)
+		g1.X, g2.X = c.x1, c.x2
+		p := NewPair(g1, g2)
+		gotPath := RoundPlus(p.GetPathOfContact(), 3)
+		gotRatio := RoundPlus(p.GetContactRatio(), 3)
+		if gotPath != c.wantPath || gotRatio != c.wantRatio {
+			t.Errorf("Pair(N %d, N %d, A %.3f, X %.3f, X %.3f) path, ratio == %.3f, %.3f want %.3f, %.3f",
+				c.n1, c.n2, c.inA, c.x1, c.x2, gotPath, gotRatio, c.wantPath, c.wantRatio)
+		}
+		if RoundPlus(p.GetApproach()+p.GetRecess(), 3) != gotPath {
+			t.Errorf("Pair(N %d, N %d) approach %.3f + recess %.3f != path %.3f",
+				c.n1, c.n2, p.GetApproach(), p.GetRecess(), gotPath)
+		}
+	}
+}
+
+func TestInvoluteIntersectAngle(t *testing.T) {
+	cases := []struct{ inbr, inr, want float64 }{
+		{100, 110, 0.458258},
+		{200, 220, 0.458258},
+		{100, 100, 0.0},
+		{100, 200, 1.732051},
+		{100, 300, 2.828427},
+	}
+	for _, c := range cases {
+		got := involuteIntersectAngle(c.inbr, c.inr)
+		if fmt.Sprintf("%0.6f", got) != fmt.Sprintf("%0.6f", c.want) {
+			t.Errorf("involuteIntersectAngle(br %f, r %f) == %f, want %f", c.inbr, c.inr, got, c.want)
+		}
+	}
+}
+
+func TestXyLocation(t *testing.T) {
+	cases := []struct{ inbr, inang, wantX, wantY float64 }{
+		{100, 0.458258, 109.955165, 3.140951},
+		{200, 0.458258, 219.910331, 6.281902},
+		{100, 0.0, 100, 0},
+		{100, 1.732051, 154.902371, 126.511906},
+		{100, 2.828427, -8.000432, 299.893291},
+	}
+	for _, c := range cases {
+		gotX, gotY := xyLocation(c.inbr, c.inang)
+		if fmt.Sprintf("%0.6f,%0.6f", gotX, gotY) !=
+			fmt.Sprintf("%0.6f,%0.6f", c.wantX, c.wantY) {
+			t.Errorf("xyLocation(br %f, ang %f) == %f,%f want %f,%f", c.inbr, c.inang,
+				gotX, gotY, c.wantX, c.wantY)
+		}
+	}
+}
+
+func TestOutline(t *testing.T) {
+	for _, n := range []int{7, 10, 17, 30} {
+		g := FromModule(1, n, 20, 0)
+		g.Rf = 0.38
+		o := g.Outline()
+		for i, s := range o {
+			next := o[(i+1)%len(o)]
+			if math.Hypot(s.End().X-next.Start().X, s.End().Y-next.Start().Y) > 1e-9 {
+				t.Errorf("Outline(N %d) segment %d ends at %v, next starts at %v",
+					n, i, s.End(), next.Start())
+			}
+			if s.Kind == Arc {
+				for _, p := range s.Points {
+					if math.Abs(p.Radius()-s.Radius) > 1e-9 {
+						t.Errorf("Outline(N %d) arc %d point %v not on radius %.6f",
+							n, i, p, s.Radius)
+					}
+				}
+			}
+		}
+		// Each tooth has two fillets, two involutes, a tip and a root arc.
+		if len(o) != 6*n {
+			t.Errorf("Outline(N %d) has %d segments, want %d", n, len(o), 6*n)
+		}
+		// The polygon must lie between the root and outside circles.
+		for _, p := range o.Polygon(0.001) {
+			r := p.Radius() * 2
+			if r < g.GetRootCircleDia()-1e-6 || r > g.GetOutsideDia()+1e-6 {
+				t.Errorf("Outline(N %d).Polygon() point %v at dia %.6f", n, p, r)
+			}
+		}
+	}
+}
+
+func TestInternalGear(t *testing.T) {
+	g := FromModule(2, 60, 20, 0)
+	g.Internal = true
+	g.X = 0.2
+	// The teeth point inwards, so tip and root swap sides of the pitch circle.
+	if got, want := g.GetTipDia(), 120-2*0.8*2; math.Abs(got-want) > 1e-9 {
+		t.Errorf("GetTipDia() == %.6f, want %.6f", got, want)
+	}
+	if got, want := g.GetRootCircleDia(), 120+2*1.4*2; math.Abs(got-want) > 1e-9 {
+		t.Errorf("GetRootCircleDia() == %.6f, want %.6f", got, want)
+	}
+	if got, want := g.GetRimDia(), g.GetRootCircleDia()+20; math.Abs(got-want) > 1e-9 {
+		t.Errorf("GetRimDia() == %.6f, want %.6f", got, want)
+	}
+	g.Rim = 150
+	if got := g.GetOutsideDia(); got != 150 {
+		t.Errorf("GetOutsideDia() == %.6f, want 150", got)
+	}
+}
+
+func TestInternalCentres(t *testing.T) {
+	g1 := FromModule(2, 20, 20, 0)
+	g2 := FromModule(2, 60, 20, 0)
+	g2.Internal = true
+	if got := WorkingCentreDistance(g1, g2); math.Abs(got-40) > 1e-9 {
+		t.Errorf("WorkingCentreDistance() == %.6f, want 40", got)
+	}
+	// Shifting the internal gear by x2 - x1 must bring it to the centres
+	// asked for.
+	g1.X = 0.1
+	g2.X = ShiftForCentres(g1, g2, 41) + g1.X
+	if got := WorkingCentreDistance(g1, g2); math.Abs(got-41) > 1e-6 {
+		t.Errorf("WorkingCentreDistance() == %.6f after ShiftForCentres, want 41",
+			got)
+	}
+}
+
+func TestInternalOutline(t *testing.T) {
+	g := FromModule(1, 40, 20, 0)
+	g.Internal = true
+	o := g.Outline()
+	for i, s := range o {
+		next := o[(i+1)%len(o)]
+		if math.Hypot(s.End().X-next.Start().X, s.End().Y-next.Start().Y) > 1e-9 {
+			t.Errorf("Outline() segment %d ends at %v, next starts at %v",
+				i, s.End(), next.Start())
+		}
+	}
+	for _, p := range o.Polygon(0.001) {
+		r := p.Radius() * 2
+		if r < g.GetTipDia()-1e-6 || r > g.GetRootCircleDia()+1e-6 {
+			t.Errorf("Outline().Polygon() point %v at dia %.6f", p, r)
+		}
+	}
+	// A tooth space is centred on the positive x axis.
+	if p := o[0].Start(); p.Y >= 0 {
+		t.Errorf("Outline() starts at %v, want below the x axis", p)
+	}
+}
+
+func TestInternalInterference(t *testing.T) {
+	cases := []struct {
+		n1, n2   int
+		trochoid bool
+	}{
+		{20, 24, true},
+		{16, 60, false},
+	}
+	for _, c := range cases {
+		g1 := FromModule(1, c.n1, 20, 0)
+		g2 := FromModule(1, c.n2, 20, 0)
+		g2.Internal = true
+		_, i2 := CheckInterference(g1, g2)
+		if i2.Trochoid != c.trochoid {
+			t.Errorf("CheckInterference(%d, %d internal) trochoid == %v, want %v",
+				c.n1, c.n2, i2.Trochoid, c.trochoid)
+		}
+		if i2.Undercut {
+			t.Errorf("CheckInterference(%d, %d internal) internal gear undercut",
+				c.n1, c.n2)
+		}
+	}
+	// The tips of the ring run past the base circle of the pinion, and
+	// sweep down to 5 mm from its centre.
+	g1 := FromModule(1, 12, 25, 0)
+	g2 := FromModule(1, 36, 25, 0)
+	g2.Internal = true
+	i1, _ := CheckInterference(g1, g2)
+	if want := (g1.GetFormCircleDia() - 10) / 2; !i1.Interference ||
+		math.Abs(i1.Depth-want) > 1e-9 {
+		t.Errorf("CheckInterference(12, 36 internal) interference %v, depth "+
+			"%.3f, want true, %.3f", i1.Interference, i1.Depth, want)
+	}
+}
+
+func TestRackOutline(t *testing.T) {
+	r := Rack{M: 2, N: 5, A: 20, Length: 40}
+	o := r.Outline()
+	for i, s := range o {
+		next := o[(i+1)%len(o)]
+		if s.End() != next.Start() {
+			t.Errorf("Outline() segment %d ends at %v, next starts at %v",
+				i, s.End(), next.Start())
+		}
+	}
+	// The back, two ends, two flanks and a tip for each tooth, and the root
+	// between and either side of them.
+	if want := 3 + 3*r.N + (r.N - 1) + 2; len(o) != want {
+		t.Errorf("Outline() has %d segments, want %d", len(o), want)
+	}
+	for _, p := range o.Polygon(0.001) {
+		if p.Y > r.GetAddendum()+1e-9 ||
+			p.Y < -r.GetDedendum()-r.GetBack()-1e-9 ||
+			math.Abs(p.X) > r.GetLength()/2+1e-9 {
+			t.Errorf("Outline() point %v outside the rack", p)
+		}
+	}
+	if got := (Rack{M: 2, N: 10}).GetLength(); math.Abs(got-20*math.Pi) > 1e-9 {
+		t.Errorf("GetLength() == %.6f, want %.6f", got, 20*math.Pi)
+	}
+	// The backlash of the gear thins each tooth by the width it turns
+	// through at the pitch circle.
+	g := FromModule(2, 20, 20, 0.5)
+	r = RackFor(g, 5)
+	if want := 0.5 * DegToRad * 20; math.Abs(r.B-want) > 1e-9 {
+		t.Errorf("RackFor() backlash %.6f, want %.6f", r.B, want)
+	}
+	if got, want := rackToothWidth(r), rackToothWidth(Rack{M: 2, N: 5,
+		A: 20})-r.B; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Outline() tooth width %.6f at the pitch line, want %.6f",
+			got, want)
+	}
+}
+
+// Return the width of the middle tooth of a rack at its pitch line, where
+// its flanks cross y = 0.
+func rackToothWidth(r Rack) float64 {
+	var xs []float64
+	for _, s := range r.Outline() {
+		a, b := s.Start(), s.End()
+		if (a.Y < 0) != (b.Y < 0) {
+			x := a.X + (b.X-a.X)*(0-a.Y)/(b.Y-a.Y)
+			if math.Abs(x) < r.GetCircularPitch()/2 {
+				xs = append(xs, x)
+			}
+		}
+	}
+	if len(xs) != 2 {
+		return math.NaN()
+	}
+	return math.Abs(xs[1] - xs[0])
+}
+
+func TestRackPair(t *testing.T) {
+	g := FromModule(1, 20, 20, 0)
+	p := RackPair{G: g, R: RackFor(g, 10)}
+	if got := p.GetContactRatio(); math.Abs(got-1.769) > 0.001 {
+		t.Errorf("GetContactRatio() == %.3f, want 1.769", got)
+	}
+	// A whole tooth moves the rack on by one pitch.
+	rot, travel := p.GetRotation(100)
+	if math.Abs(rot-(-90+18)) > 1e-9 || math.Abs(travel-math.Pi) > 1e-9 {
+		t.Errorf("GetRotation(100) == %.6f, %.6f, want -72, %.6f", rot, travel,
+			math.Pi)
+	}
+	g.X = 0.5
+	p.G = g
+	if got := p.GetCentreHeight(); math.Abs(got-10.5) > 1e-9 {
+		t.Errorf("GetCentreHeight() == %.6f, want 10.5", got)
+	}
+	if i := p.CheckInterference(); i.Interference || i.Undercut {
+		t.Errorf("CheckInterference() == %+v, want no problems", i)
+	}
+	// The tips of the rack run past the base circle of a small pinion, and
+	// sweep down to 4 mm from its centre.
+	p.G = FromModule(1, 10, 25, 0)
+	p.R = RackFor(p.G, 10)
+	i := p.CheckInterference()
+	if want := (p.G.GetFormCircleDia() - 8) / 2; !i.Interference ||
+		math.Abs(i.Depth-want) > 1e-9 {
+		t.Errorf("CheckInterference() of 10 teeth interference %v, depth "+
+			"%.3f, want true, %.3f", i.Interference, i.Depth, want)
+	}
+}
+
+// Return the area of a closed polygon, positive if it runs anticlockwise.
+func signedArea(pts []Point) float64 {
+	a := 0.0
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		a += p.X*q.Y - q.X*p.Y
+	}
+	return a / 2
+}
+
+func TestContours(t *testing.T) {
+	g1 := FromModule(2, 12, 20, 0)
+	g1.Rf = 0.38
+	g2 := FromModule(2, 40, 20, 0)
+	g2.Internal = true
+	cases := []struct {
+		name string
+		cs   []Outline
+		want []bool // Whether each contour runs anticlockwise
+	}{
+		{"external", g1.Contours(), []bool{true}},
+		{"internal", g2.Contours(), []bool{false, true}},
+		{"rack", RackFor(g1, 6).Contours(), []bool{true}},
+	}
+	for _, c := range cases {
+		if len(c.cs) != len(c.want) {
+			t.Errorf("%s Contours() returned %d contours, want %d", c.name,
+				len(c.cs), len(c.want))
+			continue
+		}
+		for i, o := range c.cs {
+			if ccw := signedArea(o.Polygon(0.01)) > 0; ccw != c.want[i] {
+				t.Errorf("%s Contours() contour %d anticlockwise == %v, want %v",
+					c.name, i, ccw, c.want[i])
+			}
+			for j, s := range o {
+				next := o[(j+1)%len(o)]
+				if math.Hypot(s.End().X-next.Start().X,
+					s.End().Y-next.Start().Y) > 1e-9 {
+					t.Errorf("%s contour %d segment %d does not join the next",
+						c.name, i, j)
+				}
+				for k := 1; k < len(s.Points); k++ {
+					if s.Points[k] == s.Points[k-1] {
+						t.Errorf("%s contour %d segment %d repeats point %v",
+							c.name, i, j, s.Points[k])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSplitContours(t *testing.T) {
+	// The kerf closes up the bore, leaving only the teeth.
+	g := FromModule(2, 30, 20, 0)
+	g.Bore = Bore{Dia: 1}
+	g.Kerf = 2
+	teeth, rest := g.SplitContours()
+	if len(teeth) != 1 || len(rest) != 0 {
+		t.Fatalf("SplitContours() returned %d and %d contours, want 1 and 0",
+			len(teeth), len(rest))
+	}
+	r := 0.0
+	for _, p := range teeth[0].Polygon(0.001) {
+		r = math.Max(r, p.Radius())
+	}
+	if math.Abs(r-g.GetTipDia()/2-1) > 0.01 {
+		t.Errorf("SplitContours() teeth reach %.3f, want %.3f", r,
+			g.GetTipDia()/2+1)
+	}
+	g = FromModule(2, 40, 20, 0)
+	g.Internal = true
+	g.Kerf = 0.5
+	teeth, rest = g.SplitContours()
+	if len(teeth) != 1 || len(rest) != 1 {
+		t.Fatalf("SplitContours() of an internal gear returned %d and %d "+
+			"contours, want 1 and 1", len(teeth), len(rest))
+	}
+	if _, hi := rest[0].Bounds(); math.Abs(hi.Y-g.GetRimDia()/2-0.25) > 0.01 {
+		t.Errorf("SplitContours() rim reaches %.3f, want %.3f", hi.Y,
+			g.GetRimDia()/2+0.25)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	g1 := FromModule(2, 12, 20, 0)
+	g2 := FromModule(2, 20, 20, 0)
+	cs := Layout(5, g1.Contours(), g2.Contours())
+	lo1, hi1 := cs[0].Bounds()
+	lo2, _ := cs[1].Bounds()
+	if math.Abs(lo1.X) > 1e-9 {
+		t.Errorf("Layout() first part starts at x %.6f, want 0", lo1.X)
+	}
+	if gap := lo2.X - hi1.X; math.Abs(gap-5) > 1e-6 {
+		t.Errorf("Layout() gap between parts == %.6f, want 5", gap)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	o := FromModule(1, 10, 20, 0).Outline().Rotate(0.1)
+	m := o.Mirror()
+	a, b := signedArea(o.Polygon(0.001)), signedArea(m.Polygon(0.001))
+	if math.Abs(a-b) > 1e-6 {
+		t.Errorf("Mirror() area == %.6f, want %.6f", b, a)
+	}
+	if p, q := o[0].Start(), m[len(m)-1].End(); p.X != q.X || p.Y != -q.Y {
+		t.Errorf("Mirror() ends at %v, want the reflection of %v", q, p)
+	}
+}
+
+func TestKeyway(t *testing.T) {
+	cases := []struct {
+		b        Bore
+		w, depth float64
+		err      bool
+	}{
+		{Bore{Dia: 10, Kind: DIN6885Bore}, 3, 1.4, false},
+		{Bore{Dia: 25, Kind: DIN6885Bore}, 8, 3.3, false},
+		{Bore{Dia: 12.7, Kind: ANSIBore}, 3.175, 1.5875, false},
+		{Bore{Dia: 25.4, Kind: ANSIBore}, 6.35, 3.175, false},
+		{Bore{Dia: 5, Kind: DIN6885Bore}, 0, 0, true},
+		{Bore{Dia: 10, Kind: RoundBore}, 0, 0, true},
+	}
+	for _, c := range cases {
+		w, depth, err := c.b.GetKeyway()
+		if (err != nil) != c.err {
+			t.Errorf("GetKeyway(%s %.3f) error == %v, want error %v", c.b.Kind,
+				c.b.Dia, err, c.err)
+			continue
+		}
+		if math.Abs(w-c.w) > 1e-9 || math.Abs(depth-c.depth) > 1e-9 {
+			t.Errorf("GetKeyway(%s %.3f) == %.4f, %.4f, want %.4f, %.4f",
+				c.b.Kind, c.b.Dia, w, depth, c.w, c.depth)
+		}
+	}
+}
+
+func TestBoreOutline(t *testing.T) {
+	cases := []struct {
+		b    Bore
+		area float64
+	}{
+		{Bore{Dia: 10}, 25 * math.Pi},
+		{Bore{Dia: 10, Kind: HexBore}, 2 * math.Sqrt(3) * 25},
+		// A flat 9 across cuts a 1 mm deep segment off the circle.
+		{Bore{Dia: 10, Kind: DFlatBore}, 25*math.Pi -
+			(25*math.Acos(0.8) - 4*3)},
+		// The keyway replaces the segment of the circle either side of it.
+		{Bore{Dia: 10, Kind: DIN6885Bore}, 25*math.Pi -
+			25*(math.Asin(0.3)-0.3*math.Sqrt(0.91)) +
+			3*(6.4-math.Sqrt(22.75))},
+	}
+	for _, c := range cases {
+		o := c.b.Outline()
+		if got := signedArea(o.Polygon(1e-6)); math.Abs(got-c.area) > 1e-4 {
+			t.Errorf("Outline(%s) area == %.6f, want %.6f", c.b, got, c.area)
+		}
+	}
+	if o := (Bore{}).Outline(); o != nil {
+		t.Errorf("Outline() with no bore == %v, want nil", o)
+	}
+}
+
+func TestBoreCheck(t *testing.T) {
+	root := FromModule(1, 12, 20, 0).GetRootCircleDia()
+	if err := (Bore{Dia: 6, Kind: DIN6885Bore}).Check(root); err == nil {
+		t.Errorf("Check() of a 6 mm bore is nil, want an error for the keyway")
+	}
+	if err := (Bore{Dia: 8, Kind: DIN6885Bore}).Check(root); err == nil {
+		t.Errorf("Check() of a keyway past the root is nil, want an error")
+	}
+	if err := (Bore{Dia: 5, Kind: HexBore}).Check(root); err != nil {
+		t.Errorf("Check() of a 5 mm hex == %v, want nil", err)
+	}
+	g := FromModule(2, 20, 20, 0)
+	g.Bore = Bore{Dia: 10, Kind: DIN6885Bore}
+	if cs := g.Contours(); len(cs) != 2 || signedArea(cs[0].Polygon(0.01)) > 0 {
+		t.Errorf("Contours() with a bore does not start with a clockwise hole")
+	}
+}
+
+func TestParseBoreKind(t *testing.T) {
+	for _, k := range []BoreKind{RoundBore, DIN6885Bore, ANSIBore, DFlatBore,
+		HexBore} {
+		if got, err := ParseBoreKind(k.String()); got != k || err != nil {
+			t.Errorf("ParseBoreKind(%q) == %v, %v, want %v", k.String(), got,
+				err, k)
+		}
+	}
+	if _, err := ParseBoreKind("square"); err == nil {
+		t.Errorf("ParseBoreKind(\"square\") returned no error")
+	}
+}
+
+func TestMoments(t *testing.T) {
+	a, j := circleOutline(10, false).moments()
+	if math.Abs(a-100*math.Pi) > 1e-3 || math.Abs(j-math.Pi*1e4/2) > 1 {
+		t.Errorf("moments() of a circle == %.3f, %.3f, want %.3f, %.3f", a, j,
+			100*math.Pi, math.Pi*1e4/2)
+	}
+	// A circle off the origin picks up its area times the distance squared.
+	_, j = circleOutline(10, false).Translate(20, 0).moments()
+	if want := math.Pi*1e4/2 + 100*math.Pi*400; math.Abs(j-want) > 1 {
+		t.Errorf("moments() of a moved circle == %.3f, want %.3f", j, want)
+	}
+}
+
+func TestWeb(t *testing.T) {
+	g := FromModule(2, 40, 20, 0)
+	g.Bore = Bore{Dia: 12}
+	solid := g.GetArea()
+	g.Web = Web{Kind: HoleWeb, N: 6, Size: 10}
+	if err := g.CheckWeb(); err != nil {
+		t.Fatalf("CheckWeb() == %v, want nil", err)
+	}
+	if got, want := g.GetArea(), solid-6*25*math.Pi; math.Abs(got-want) > 0.01 {
+		t.Errorf("GetArea() with holes == %.3f, want %.3f", got, want)
+	}
+	if got, want := g.GetMass(10, 1000), g.GetArea()*1e-5; math.Abs(got-want) > 1e-9 {
+		t.Errorf("GetMass() == %.6f, want %.6f", got, want)
+	}
+	if got := len(g.Holes()); got != 7 {
+		t.Errorf("Holes() returned %d outlines, want 7", got)
+	}
+	g.Web = Web{Kind: SpokeWeb, N: 4}
+	if err := g.CheckWeb(); err != nil {
+		t.Errorf("CheckWeb() with spokes == %v, want nil", err)
+	}
+	if g.GetArea() >= solid {
+		t.Errorf("GetArea() with spokes == %.3f, not less than solid %.3f",
+			g.GetArea(), solid)
+	}
+	// The rim, hub and pitch circle can each be given.
+	g.Web = Web{Kind: HoleWeb, N: 6, Size: 8, Rim: 8, Hub: 24, PCD: 40}
+	if err := g.CheckWeb(); err != nil {
+		t.Errorf("CheckWeb() with the rim, hub and pitch circle == %v, want "+
+			"nil", err)
+	}
+	if got, want := g.GetWebRimDia(), g.GetRootCircleDia()-16; got != want ||
+		g.GetHubDia() != 24 || g.GetHolePCD() != 40 {
+		t.Errorf("web rim %.3f, hub %.3f, pitch circle %.3f, want %.3f, 24, 40",
+			got, g.GetHubDia(), g.GetHolePCD(), want)
+	}
+	for _, w := range []Web{
+		{Kind: HoleWeb, N: 12, Size: 15},
+		{Kind: HoleWeb, N: 6, Size: 10, Hub: 60},
+		{Kind: SpokeWeb, N: 40, Size: 5},
+		{Kind: HoleWeb},
+		{Kind: HoleWeb, N: 6, Rim: -1},
+		{Kind: HoleWeb, N: 6, Hub: math.NaN()},
+		{Kind: HoleWeb, N: 6, Size: 8, PCD: 26},
+		{Kind: HoleWeb, N: 6, Rim: 30},
+		{Kind: SpokeWeb, N: 4, PCD: 40},
+	} {
+		g.Web = w
+		if err := g.CheckWeb(); err == nil {
+			t.Errorf("CheckWeb(%+v) == nil, want an error", w)
+		}
+	}
+}
+
+func TestFromNormal(t *testing.T) {
+	g := FromNormal(2, 20, 20, 0, 15)
+	if math.Abs(g.Pd-41.411) > 0.001 {
+		t.Errorf("FromNormal(2, 20, 20, 0, 15).Pd == %.3f, want 41.411", g.Pd)
+	}
+	if math.Abs(g.A-20.6469) > 0.0001 {
+		t.Errorf("FromNormal(2, 20, 20, 0, 15).A == %.4f, want 20.6469", g.A)
+	}
+	if got := g.GetNormalModule(); math.Abs(got-2) > 1e-9 {
+		t.Errorf("GetNormalModule() == %.6f, want 2", got)
+	}
+	if got := g.GetNormalPressureAngle(); math.Abs(got-20) > 1e-9 {
+		t.Errorf("GetNormalPressureAngle() == %.6f, want 20", got)
+	}
+	// Heights are set by the normal module.
+	if got := g.GetAddendum(); math.Abs(got-2) > 1e-9 {
+		t.Errorf("GetAddendum() == %.6f, want 2", got)
+	}
+	// A spur gear is unchanged.
+	s := FromNormal(2, 20, 20, 0, 0)
+	if s != FromModule(2, 20, 20, 0) {
+		t.Errorf("FromNormal() with no helix == %+v, want %+v", s,
+			FromModule(2, 20, 20, 0))
+	}
+}
+
+func TestHelicalCentres(t *testing.T) {
+	g1 := FromNormal(3, 12, 20, 0, 30)
+	g2 := FromNormal(3, 60, 20, 0, 30)
+	g1.X = 0.09809
+	if got := OperatingPressureAngle(g1, g2); math.Abs(got-23.1126) > 0.0001 {
+		t.Errorf("OperatingPressureAngle() == %.4f, want 23.1126", got)
+	}
+	if got := WorkingCentreDistance(g1, g2); math.Abs(got-125) > 0.001 {
+		t.Errorf("WorkingCentreDistance() == %.3f, want 125.000", got)
+	}
+	if got := ShiftForCentres(g1, g2, 125); math.Abs(got-0.09809) > 0.0001 {
+		t.Errorf("ShiftForCentres() == %.5f, want 0.09809", got)
+	}
+}
+
+func TestHelicalPair(t *testing.T) {
+	g1 := FromNormal(2, 20, 20, 0, 15)
+	g2 := FromNormal(2, 40, 20, 0, 15)
+	g1.Face, g2.Face = 20, 30
+	g2.Hand = LeftHand
+	p := Pair{G1: g1, G2: g2, C: WorkingCentreDistance(g1, g2)}
+	want := 20 * math.Sin(15*DegToRad) / (2 * math.Pi)
+	if got := p.GetAxialContactRatio(); math.Abs(got-want) > 1e-9 {
+		t.Errorf("GetAxialContactRatio() == %.4f, want %.4f", got, want)
+	}
+	if got := p.GetTotalContactRatio(); math.Abs(got-p.GetContactRatio()-want) > 1e-9 {
+		t.Errorf("GetTotalContactRatio() == %.4f, want %.4f", got,
+			p.GetContactRatio()+want)
+	}
+	if err := p.CheckHelix(); err != nil {
+		t.Errorf("CheckHelix() == %v, want nil", err)
+	}
+	p.G2.Hand = RightHand
+	if err := p.CheckHelix(); err == nil {
+		t.Error("CheckHelix() with the same hands == nil, want an error")
+	}
+	p.G2.Internal = true
+	if err := p.CheckHelix(); err != nil {
+		t.Errorf("CheckHelix() internal with the same hands == %v, want nil",
+			err)
+	}
+	p.G2.Helix = 20
+	if err := p.CheckHelix(); err == nil {
+		t.Error("CheckHelix() with different helix angles == nil, want an error")
+	}
+}
+
+func TestTwist(t *testing.T) {
+	g := FromNormal(2, 20, 20, 0, 15)
+	g.Face = 10
+	want := 10 * math.Tan(15*DegToRad) / (g.Pd / 2)
+	if got := g.GetTwist(); math.Abs(got-want) > 1e-9 {
+		t.Errorf("GetTwist() == %.6f, want %.6f", got, want)
+	}
+	g.Hand = LeftHand
+	if got := g.GetTwist(); math.Abs(got+want) > 1e-9 {
+		t.Errorf("GetTwist() left hand == %.6f, want %.6f", got, -want)
+	}
+	// The section turns with the helix, half way at half the face.
+	base := g.SectionAt(0)[0].Start()
+	got := g.SectionAt(5)[0].Start()
+	if d := math.Atan2(got.Y, got.X) - math.Atan2(base.Y, base.X); math.Abs(d+want/2) > 1e-9 {
+		t.Errorf("SectionAt(5) turned %.6f, want %.6f", d, -want/2)
+	}
+	if got, want := g.GetLead(), math.Pi*g.Pd/math.Tan(15*DegToRad); math.Abs(got-want) > 1e-9 {
+		t.Errorf("GetLead() == %.3f, want %.3f", got, want)
+	}
+}
+
+func TestParseHand(t *testing.T) {
+	for _, h := range []Hand{RightHand, LeftHand} {
+		if got, err := ParseHand(h.String()); got != h || err != nil {
+			t.Errorf("ParseHand(%q) == %v, %v, want %v", h.String(), got, err, h)
+		}
+		if h.Opposite() == h {
+			t.Errorf("%v.Opposite() == %v", h, h)
+		}
+	}
+	if _, err := ParseHand("up"); err == nil {
+		t.Error("ParseHand(\"up\") returned no error")
+	}
+}
+
+func TestOffset(t *testing.T) {
+	square := Outline{
+		{Kind: Line, Points: []Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}},
+	}
+	for _, c := range []struct {
+		d, area float64
+		n       int
+	}{
+		{0, 100, 1},
+		{1, 100 + 40 + math.Pi, 1},
+		{-1, 64, 1},
+		{-4.9, 0.04, 1},
+		{-5.1, 0, 0},
+	} {
+		os := square.Offset(c.d, 0.001)
+		if len(os) != c.n {
+			t.Errorf("Offset(%g) returned %d outlines, want %d", c.d, len(os), c.n)
+			continue
+		}
+		if c.n == 0 {
+			continue
+		}
+		if got := signedArea(os[0].Polygon(0.001)); math.Abs(got-c.area) > 0.01 {
+			t.Errorf("Offset(%g) has area %.4f, want %.4f", c.d, got, c.area)
+		}
+	}
+}
+
+func TestOffsetGear(t *testing.T) {
+	// Offset out into the tooth spaces, the path of a cutter can reach the
+	// root only if it is narrower than the root gap.
+	g := FromModule(2, 60, 20, 0)
+	g.Rf = 0.38
+	gap := g.GetRootGap()
+	root := g.GetRootCircleDia() / 2
+	for _, dia := range []float64{gap - 0.2, gap + 1} {
+		os := g.Contours()[0].Offset(dia/2, 0.01)
+		if len(os) != 1 {
+			t.Fatalf("Offset(%.3f) returned %d outlines, want 1", dia/2, len(os))
+		}
+		low := math.Inf(1)
+		for _, p := range os[0].Polygon(0.01) {
+			low = math.Min(low, p.Radius())
+		}
+		reached := low < root+dia/2+0.05
+		if want := dia < gap; reached != want {
+			t.Errorf("a %.3f mm cutter reached the root %v, want %v", dia,
+				reached, want)
+		}
+	}
+}
+
+func TestOffsetSmall(t *testing.T) {
+	// The kerf of a laser is a few microns, and must not take longer than
+	// a wide one.
+	g := FromModule(2, 40, 20, 0)
+	g.Rf = 0.38
+	o := g.Outline()
+	start := time.Now()
+	os := o.Offset(0.0005, kerfTolerance)
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Offset(0.0005) took %v", d)
+	}
+	if len(os) != 1 {
+		t.Fatalf("Offset(0.0005) returned %d outlines, want 1", len(os))
+	}
+	r := 0.0
+	for _, p := range os[0].Polygon(0.001) {
+		r = math.Max(r, p.Radius())
+	}
+	if want := g.GetTipDia()/2 + 0.0005; math.Abs(r-want) > 1e-4 {
+		t.Errorf("Offset(0.0005) reaches %.5f, want %.5f", r, want)
+	}
+}
+
+func TestRootGap(t *testing.T) {
+	for _, c := range []struct {
+		g    Gear
+		want float64
+	}{
+		{FromModule(2, 60, 20, 0), 2.013},
+		{FromModule(2, 12, 20, 0), 2.616},
+	} {
+		c.g.Rf = 0.38
+		if got := c.g.GetRootGap(); math.Abs(got-c.want) > 0.01 {
+			t.Errorf("GetRootGap() for %d teeth == %.3f, want %.3f", c.g.N, got,
+				c.want)
+		}
+	}
+	r := RackFor(FromModule(2, 12, 20, 0), 10)
+	if got := r.GetRootGap(); got <= 0 || got >= r.GetCircularPitch()/2 {
+		t.Errorf("rack GetRootGap() == %.3f", got)
+	}
+}
+
+func TestKerf(t *testing.T) {
+	g := FromModule(2, 30, 20, 0)
+	g.Bore = Bore{Dia: 10}
+	plain := g.Contours()
+	area := g.GetArea()
+	g.Kerf = 0.2
+	cs := g.Contours()
+	if len(cs) != len(plain) {
+		t.Fatalf("Contours() with kerf returned %d contours, want %d", len(cs),
+			len(plain))
+	}
+	// The bore shrinks and the outside grows, each by half the kerf. The
+	// bore is made of chords a little inside the true circle.
+	bore := signedArea(cs[0].Polygon(0.001))
+	if want := -math.Pi * 4.9 * 4.9; math.Abs(bore-want) > 0.05 {
+		t.Errorf("bore with kerf has area %.3f, want %.3f", bore, want)
+	}
+	lo, hi := cs[1].Bounds()
+	if got, want := hi.X-lo.X, g.GetTipDia()+0.2; math.Abs(got-want) > 0.01 {
+		t.Errorf("gear with kerf is %.3f across, want %.3f", got, want)
+	}
+	if got := g.GetArea(); got != area {
+		t.Errorf("GetArea() with kerf == %.3f, want %.3f as designed", got, area)
+	}
+	r := RackFor(g, 5)
+	lo, hi = r.Contours()[0].Bounds()
+	if got, want := hi.X-lo.X, r.GetLength()+0.2; math.Abs(got-want) > 1e-6 {
+		t.Errorf("rack with kerf is %.3f long, want %.3f", got, want)
+	}
+}
+
+// Report whether the outlines of two placed gears cross anywhere.
+func gearsCross(g1 Gear, p1 Placement, g2 Gear, p2 Placement) bool {
+	place := func(g Gear, p Placement) []Point {
+		return g.Outline().Rotate(p.Rot*DegToRad).Translate(p.Centre.X,
+			p.Centre.Y).Polygon(0.01)
+	}
+	a, b := place(g1, p1), place(g2, p2)
+	for i := range a {
+		for j := range b {
+			if _, ok := crossing(a[i], a[(i+1)%len(a)], b[j],
+				b[(j+1)%len(b)]); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestTrain(t *testing.T) {
+	gear := func(n int, internal bool) Gear {
+		g := FromModule(1, n, 20, 1)
+		g.Internal = internal
+		return g
+	}
+	tr := Train{Gears: []TrainGear{
+		{Gear: gear(12, false)},
+		{Gear: gear(31, false), From: 0, Angle: 37},
+		{Gear: gear(15, false), From: 1, Compound: true},
+		{Gear: gear(20, false), From: 2, Angle: -70},
+		{Gear: gear(45, true), From: 3, Angle: 200},
+	}}
+	for _, rotfrac := range []float64{0, 30, 75} {
+		ps, err := tr.Layout(rotfrac)
+		if err != nil {
+			t.Fatalf("Layout() returned %v", err)
+		}
+		for i, tg := range tr.Gears[1:] {
+			i++
+			if tg.Compound {
+				continue
+			}
+			d := tr.Gears[tg.From]
+			if tg.Gear.Internal {
+				// The flanks touch, and the chords of the hollow flanks of an
+				// internal gear always cross, so check against a pair turned
+				// to the same angle instead.
+				phi := tg.Angle + 180
+				rf := (ps[tg.From].Rot - phi) * float64(d.Gear.N) / 3.6
+				_, r2 := Pair{G1: d.Gear, G2: tg.Gear}.GetRotations(rf)
+				pitch := 360 / float64(tg.Gear.N)
+				diff := math.Mod(ps[i].Rot-r2-phi, pitch)
+				if math.Abs(diff) > 1e-6 && math.Abs(math.Abs(diff)-pitch) > 1e-6 {
+					t.Errorf("internal gear %d is %.4f degrees out at %g%%", i+1,
+						diff, rotfrac)
+				}
+			} else if gearsCross(d.Gear, ps[tg.From], tg.Gear, ps[i]) {
+				t.Errorf("gears %d and %d cross at %g%%", tg.From+1, i+1, rotfrac)
+			}
+			// Half a pitch out, the teeth clash.
+			p := ps[i]
+			p.Rot += 180 / float64(tg.Gear.N)
+			if !gearsCross(d.Gear, ps[tg.From], tg.Gear, p) {
+				t.Errorf("gears %d and %d do not clash half a pitch out",
+					tg.From+1, i+1)
+			}
+		}
+	}
+	ps, _ := tr.Layout(0)
+	if d := ps[1].Centre.Radius(); math.Abs(d-21.5) > 1e-9 {
+		t.Errorf("second gear is %.3f from the first, want 21.5", d)
+	}
+	if ps[2].Centre != ps[1].Centre {
+		t.Errorf("compound gear is not on the shaft of the gear before it")
+	}
+	want := (31.0 / 12) * (20.0 / 15) * (45.0 / 20)
+	if got := tr.GetRatio(); math.Abs(got-want) > 1e-9 {
+		t.Errorf("GetRatio() == %.4f, want %.4f", got, want)
+	}
+	if got := 1 / ps[4].Speed; math.Abs(got-want) > 1e-9 {
+		t.Errorf("speed of the last gear gives a ratio of %.4f, want %.4f", got,
+			want)
+	}
+	if n := len(tr.Pairs()); n != 3 {
+		t.Errorf("Pairs() returned %d pairs, want 3", n)
+	}
+	bad := Train{Gears: []TrainGear{{Gear: gear(12, false)},
+		{Gear: FromModule(2, 20, 20, 0)}}}
+	if err := bad.Check(); err == nil {
+		t.Error("Check() of gears with different modules returned no error")
+	}
+}
+
+func TestPlanetary(t *testing.T) {
+	p := NewPlanetary(FromModule(1, 12, 20, 1), FromModule(1, 48, 20, 1), 3)
+	if err := p.Check(); err != nil {
+		t.Fatalf("Check() returned %v", err)
+	}
+	if p.Planet.N != 18 || !p.Ring.Internal {
+		t.Errorf("NewPlanetary() made %d planet teeth and internal %v, want 18 "+
+			"and true", p.Planet.N, p.Ring.Internal)
+	}
+	for _, c := range []struct {
+		fixed Member
+		want  float64
+	}{{RingMember, 5}, {CarrierMember, -4}, {SunMember, 1.25}} {
+		if got := p.GetRatio(c.fixed); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("GetRatio(%s) == %.4f, want %.4f", c.fixed, got, c.want)
+		}
+	}
+	for _, fixed := range []Member{RingMember, CarrierMember, SunMember} {
+		first, err := p.Layout(fixed, 0)
+		if err != nil {
+			t.Fatalf("Layout(%s) returned %v", fixed, err)
+		}
+		for _, rotfrac := range []float64{0, 40, 130} {
+			l, _ := p.Layout(fixed, rotfrac)
+			if len(l.Planets) != 3 {
+				t.Fatalf("Layout() placed %d planets, want 3", len(l.Planets))
+			}
+			for k, pp := range l.Planets {
+				if d := pp.Centre.Radius(); math.Abs(d-15) > 1e-9 {
+					t.Errorf("planet %d is %.3f from the sun, want 15", k+1, d)
+				}
+				if gearsCross(p.Sun, l.Sun, p.Planet, pp) {
+					t.Errorf("sun and planet %d cross with the %s fixed at "+
+						"%g%%", k+1, fixed, rotfrac)
+				}
+				// Each planet turns at its speed as the input turns.
+				in := 360 / float64(p.Sun.N)
+				if fixed == SunMember {
+					in = 360 / float64(p.Ring.N)
+				}
+				pitch := 360 / float64(p.Planet.N)
+				diff := math.Mod(pp.Rot-first.Planets[k].Rot-
+					pp.Speed*in*rotfrac/100, pitch)
+				if math.Abs(diff) > 1e-6 && math.Abs(math.Abs(diff)-pitch) > 1e-6 {
+					t.Errorf("planet %d is %.4f degrees out with the %s fixed "+
+						"at %g%%", k+1, diff, fixed, rotfrac)
+				}
+				// Check the ring against a pair turned to the same angle, as
+				// for a train.
+				phi := math.Atan2(pp.Centre.Y, pp.Centre.X)*RadToDeg + 180
+				rf := (pp.Rot - phi) * float64(p.Planet.N) / 3.6
+				_, r2 := Pair{G1: p.Planet, G2: p.Ring}.GetRotations(rf)
+				pitch = 360 / float64(p.Ring.N)
+				diff = math.Mod(l.Ring.Rot-r2-phi, pitch)
+				if math.Abs(diff) > 1e-6 && math.Abs(math.Abs(diff)-pitch) > 1e-6 {
+					t.Errorf("ring is %.4f degrees out at planet %d with the %s "+
+						"fixed at %g%%", diff, k+1, fixed, rotfrac)
+				}
+			}
+			// The fixed member stays where it is.
+			var moved float64
+			switch fixed {
+			case RingMember:
+				moved = l.Ring.Rot - first.Ring.Rot
+			case CarrierMember:
+				moved = l.Carrier.Rot - first.Carrier.Rot
+			case SunMember:
+				moved = l.Sun.Rot - first.Sun.Rot
+			}
+			if math.Abs(moved) > 1e-6 {
+				t.Errorf("the fixed %s turned %.4f degrees at %g%%", fixed,
+					moved, rotfrac)
+			}
+		}
+	}
+	bad := []struct {
+		sun, ring, n int
+		why          string
+	}{
+		{12, 47, 3, "not coaxial"},
+		{12, 48, 7, "not evenly spaced"},
+		{12, 48, 6, "overlapping planets"},
+		{12, 48, 0, "no planets"},
+		{12, 13, 1, "no room for planets"},
+	}
+	for _, b := range bad {
+		p := NewPlanetary(FromModule(1, b.sun, 20, 1),
+			FromModule(1, b.ring, 20, 1), b.n)
+		if err := p.Check(); err == nil {
+			t.Errorf("Check() of a set with %s returned no error", b.why)
+		}
+	}
+	if _, err := ParseMember("planet"); err == nil {
+		t.Error("ParseMember(\"planet\") returned no error")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := Search{Ratio: 3, Tolerance: 0.02, Modules: []float64{1}, MinTeeth: 15,
+		MaxTeeth: 60, Stages: 1, A: 20, Rf: 0.38, Limit: 10}
+	sols, err := s.Run()
+	if err != nil {
+		t.Fatalf("Run() returned %v", err)
+	}
+	if len(sols) != 10 {
+		t.Fatalf("Run() returned %d solutions, want 10", len(sols))
+	}
+	for i, sol := range sols {
+		if sol.Error > s.Tolerance {
+			t.Errorf("solution %s is %.4f out", sol, sol.Error)
+		}
+		if i > 0 && sols[i].better(sols[i-1]) {
+			t.Errorf("solution %s ranks after %s", sols[i], sols[i-1])
+		}
+	}
+	// Within the tolerance the hunting trains come first, and of those the
+	// largest gears have the most contact.
+	if got := sols[0].String(); got != "20:59 m1" {
+		t.Errorf("best solution is %s, want 20:59 m1", got)
+	}
+	// Hunting comes before contact ratio, contact ratio before size, and
+	// size before error.
+	a := Solution{Error: 0.002, Hunting: true, ContactRatio: 1.4, Size: 90}
+	b := Solution{Error: 0.001, ContactRatio: 1.6, Size: 80}
+	if !a.better(b) || b.better(a) {
+		t.Error("a train that does not hunt ranks before one that does")
+	}
+	b.Hunting = true
+	if a.better(b) {
+		t.Error("less contact ranks before more")
+	}
+	b.ContactRatio = a.ContactRatio
+	if a.better(b) {
+		t.Error("a larger train ranks before a smaller one")
+	}
+	b.Size = a.Size
+	if a.better(b) {
+		t.Error("a larger error ranks before a smaller one")
+	}
+	// No stage has less than the least contact ratio.
+	s.MinContactRatio = 1.65
+	if sols, err = s.Run(); err != nil || len(sols) == 0 {
+		t.Fatalf("Run() with a least contact ratio returned %d, %v",
+			len(sols), err)
+	}
+	for _, sol := range sols {
+		if sol.ContactRatio < s.MinContactRatio {
+			t.Errorf("solution %s has a contact ratio of %.3f", sol,
+				sol.ContactRatio)
+		}
+	}
+	// A large ratio needs more than one stage, each within the centres.
+	s = Search{Ratio: 20, Tolerance: 0.001, Modules: []float64{1, 1.5},
+		MinTeeth: 12, MaxTeeth: 80, MaxCentres: 50, Stages: 2, A: 20,
+		Rf: 0.38, Limit: 5}
+	sols, err = s.Run()
+	if err != nil {
+		t.Fatalf("Run() returned %v", err)
+	}
+	if len(sols) == 0 {
+		t.Fatal("Run() found no two stage trains with a ratio of 20")
+	}
+	for _, sol := range sols {
+		if len(sol.Stages) != 2 || math.Abs(sol.Ratio-20)/20 > 0.001 {
+			t.Errorf("solution %s has ratio %.4f", sol, sol.Ratio)
+		}
+		for _, st := range sol.Stages {
+			if st.Centres > 50 {
+				t.Errorf("stage of solution %s is %.3f mm between centres",
+					sol, st.Centres)
+			}
+		}
+	}
+	for _, bad := range []Search{
+		{Ratio: 0, Modules: []float64{1}, MinTeeth: 12, MaxTeeth: 20, Stages: 1},
+		{Ratio: 2, MinTeeth: 12, MaxTeeth: 20, Stages: 1},
+		{Ratio: 2, Modules: []float64{1}, MinTeeth: 20, MaxTeeth: 12, Stages: 1},
+		{Ratio: 2, Modules: []float64{1}, MinTeeth: 12, MaxTeeth: 20, Stages: 4},
+		{Ratio: 2, Modules: []float64{1}, MinTeeth: 12, MaxTeeth: 20, Stages: 1,
+			MinContactRatio: -1},
+	} {
+		if _, err := bad.Run(); err == nil {
+			t.Errorf("Run() of %+v returned no error", bad)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if err := FromModule(1, 12, 20, 0).Check(); err != nil {
+		t.Errorf("Check() of a good gear returned %v", err)
+	}
+	for _, c := range []struct {
+		g     Gear
+		field string
+	}{
+		{FromModule(1, 0, 20, 0), "N"},
+		{Gear{Pd: -12, N: 12, A: 20}, "Pd"},
+		{Gear{Pd: math.NaN(), N: 12, A: 20}, "Pd"},
+		{Gear{Pd: 12, N: 12, A: math.NaN()}, "A"},
+		{Gear{Pd: 12, N: 12, A: 20, X: math.Inf(1)}, "X"},
+	} {
+		var pe *ParamError
+		if err := c.g.Check(); !errors.As(err, &pe) || pe.Field != c.field {
+			t.Errorf("Check() of %+v returned %v, want an error in %s", c.g,
+				err, c.field)
+		}
+	}
+	// Each value is a number, but the shift undercuts the whole tooth.
+	var se *ShapeError
+	g := Gear{Pd: 12, N: 12, A: 20, X: -3}
+	if err := g.Check(); !errors.As(err, &se) {
+		t.Errorf("Check() of %+v returned %v, want a ShapeError", g, err)
+	}
+	// The tip of a small ring lies inside its base circle, where there is
+	// no involute, but the ring can still be drawn.
+	ring := FromModule(1, 30, 20, 0)
+	ring.Internal = true
+	if err := ring.Check(); err != nil {
+		t.Errorf("Check() of a 30 tooth ring returned %v", err)
+	}
+	if err := ring.Validate(); err != nil {
+		t.Errorf("Validate() of a 30 tooth ring returned %v", err)
+	}
+	var pe *ParamError
+	p := NewPair(FromModule(1, 12, 20, 0), FromModule(1, 0, 20, 0))
+	if err := p.Check(); !errors.As(err, &pe) ||
+		!strings.HasPrefix(err.Error(), "second gear: ") {
+		t.Errorf("Check() of a pair with no teeth returned %v", err)
+	}
+	r := Rack{M: 1, N: 0, A: 20}
+	if err := r.Check(); !errors.As(err, &pe) {
+		t.Errorf("Check() of a rack with no teeth returned %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, g := range []Gear{FromModule(1, 12, 20, 0), FromModule(2, 7, 25, 0.5),
+		FromNormal(1, 20, 20, 0, 30)} {
+		if err := g.Validate(); err != nil {
+			t.Errorf("Validate() of %+v returned %v", g, err)
+		}
+	}
+	ring := FromModule(1, 48, 20, 0)
+	ring.Internal = true
+	if err := ring.Validate(); err != nil {
+		t.Errorf("Validate() of an internal gear returned %v", err)
+	}
+	// Every problem is reported at once.
+	g := Gear{Pd: 12, N: 0, A: 95, B: -1, Helix: math.NaN()}
+	err := g.Validate()
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("Validate() of %+v returned %v, want a ValidationError", g,
+			err)
+	}
+	var fields []string
+	for _, e := range ve.Errors {
+		var pe *ParamError
+		if !errors.As(e, &pe) {
+			t.Fatalf("Validate() found %v, want a ParamError", e)
+		}
+		fields = append(fields, pe.Field)
+	}
+	if got := strings.Join(fields, ","); got != "N,A,B,Helix" {
+		t.Errorf("Validate() found problems in %s, want N,A,B,Helix", got)
+	}
+	var pe *ParamError
+	if !errors.As(err, &pe) || pe.Field != "N" {
+		t.Errorf("errors.As() found %v in the ValidationError, want N", pe)
+	}
+	// Sound parameters that make a poor gear.
+	g = FromModule(1, 8, 20, 0)
+	g.X = 1.2
+	if err := g.Validate(); err == nil {
+		t.Error("Validate() of pointed teeth returned no error")
+	}
+	g = FromModule(1, 12, 20, 0)
+	g.Bore = Bore{Dia: 11}
+	if err := g.Validate(); err == nil ||
+		!strings.HasPrefix(err.Error(), "bore: ") {
+		t.Errorf("Validate() of a bore through the teeth returned %v", err)
+	}
+	ring.Rim = 45
+	if err := ring.Validate(); err == nil {
+		t.Error("Validate() of a rim inside the teeth returned no error")
+	}
+	if _, err := NewGear(1, 4, 20, 0); err == nil {
+		t.Error("NewGear() of 4 teeth returned no error")
+	}
+	if _, err := NewHelicalGear(1, 20, 20, 0, 50); err == nil {
+		t.Error("NewHelicalGear() of a 50 degree helix returned no error")
+	}
+	if g, err := NewGear(1.5, 20, 20, 0); err != nil || g.Pd != 30 {
+		t.Errorf("NewGear() returned %+v and %v", g, err)
+	}
+}
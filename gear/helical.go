@@ -0,0 +1,151 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"fmt"
+	"math"
+)
+
+// The hand of the helix of a helical gear.
+type Hand int
+
+const (
+	RightHand Hand = iota // Teeth twist anticlockwise moving up the axis
+	LeftHand              // Teeth twist clockwise moving up the axis
+)
+
+// Return the name of the hand, as given to ParseHand.
+func (h Hand) String() string {
+	if h == LeftHand {
+		return "left"
+	}
+	return "right"
+}
+
+// Return the hand with the given name.
+func ParseHand(s string) (Hand, error) {
+	switch s {
+	case "right":
+		return RightHand, nil
+	case "left":
+		return LeftHand, nil
+	}
+	return RightHand, fmt.Errorf("unknown hand %q", s)
+}
+
+// Return the other hand.
+func (h Hand) Opposite() Hand {
+	if h == LeftHand {
+		return RightHand
+	}
+	return LeftHand
+}
+
+// Create a helical gear from its normal module, number of teeth, normal
+// pressure angle, backlash angle and helix angle. The pitch diameter and
+// pressure angle of the gear are converted to the transverse plane.
+func FromNormal(mn float64, n int, an float64, b float64, helix float64) Gear {
+	return Gear{
+		Pd:    mn * float64(n) / math.Cos(helix*DegToRad),
+		N:     n,
+		A:     TransverseAngle(an, helix),
+		B:     b,
+		Helix: helix,
+	}
+}
+
+// Convert the normal pressure angle an of a gear with the given helix angle
+// to the transverse plane. All angles are in degrees, and an angle out of
+// range stays on the same side of 90 degrees so that it can be reported.
+func TransverseAngle(an float64, helix float64) float64 {
+	return math.Atan2(math.Sin(an*DegToRad),
+		math.Cos(an*DegToRad)*math.Cos(helix*DegToRad)) * RadToDeg
+}
+
+// Calculate and return the normal module, measured square to the teeth. For
+// a spur gear this is the same as the module.
+func (g Gear) GetNormalModule() float64 {
+	return g.GetModule() * math.Cos(g.Helix*DegToRad)
+}
+
+// Calculate and return the normal pressure angle (degrees), measured square
+// to the teeth. For a spur gear this is the same as the pressure angle.
+func (g Gear) GetNormalPressureAngle() float64 {
+	return math.Atan(math.Tan(g.A*DegToRad)*math.Cos(g.Helix*DegToRad)) *
+		RadToDeg
+}
+
+// Calculate and return the lead, the distance along the axis in which a
+// tooth makes one full turn. A spur gear has an infinite lead.
+func (g Gear) GetLead() float64 {
+	return math.Pi * g.Pd / math.Tan(g.Helix*DegToRad)
+}
+
+// Calculate and return the angle (radians) the teeth turn through from one
+// face of the gear to the other. It is positive for a right hand helix.
+func (g Gear) GetTwist() float64 {
+	t := g.Face * math.Tan(g.Helix*DegToRad) / (g.Pd / 2)
+	if g.Hand == LeftHand {
+		return -t
+	}
+	return t
+}
+
+// Return the outline of the gear in the transverse plane at height z along
+// the axis, with z = 0 on the lower face. The outline turns with the helix.
+func (g Gear) SectionAt(z float64) Outline {
+	if g.Face == 0 || g.Helix == 0 {
+		return g.Outline()
+	}
+	return g.Outline().Rotate(g.GetTwist() * z / g.Face)
+}
+
+// Calculate and return the axial contact ratio, the number of pitches the
+// teeth advance along the axis over the face width of the narrower gear.
+func (p Pair) GetAxialContactRatio() float64 {
+	f := math.Min(p.G1.Face, p.G2.Face)
+	return f * math.Sin(p.G1.Helix*DegToRad) /
+		(math.Pi * p.G1.GetNormalModule())
+}
+
+// Calculate and return the total contact ratio, transverse and axial.
+func (p Pair) GetTotalContactRatio() float64 {
+	return p.GetContactRatio() + p.GetAxialContactRatio()
+}
+
+// Check that the helices of a pair of gears will mesh. The helix angles must
+// be the same, with opposite hands for a pair of external gears and the
+// same hand if G2 is internal.
+func (p Pair) CheckHelix() error {
+	g1, g2 := p.G1, p.G2
+	if math.Abs(g1.Helix-g2.Helix) > 1e-9 {
+		return fmt.Errorf("helix angles %.3f and %.3f differ", g1.Helix,
+			g2.Helix)
+	}
+	if g1.Helix == 0 {
+		return nil
+	}
+	if g2.Internal && g1.Hand != g2.Hand {
+		return fmt.Errorf("an internal gear needs the same hand of helix as " +
+			"its pinion")
+	}
+	if !g2.Internal && g1.Hand == g2.Hand {
+		return fmt.Errorf("external helical gears need opposite hands")
+	}
+	return nil
+}
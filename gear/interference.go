@@ -0,0 +1,133 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"fmt"
+	"math"
+)
+
+// Structure to hold the result of checking one gear of a meshing pair for
+// undercut, and for interference with the tip of its mate.
+type Interference struct {
+	MinTeeth     int     // Fewest teeth that can be cut without undercut
+	Undercut     bool    // The involute is cut away by the tip of the cutter
+	FormDia      float64 // Diameter below which the flank is not involute
+	ContactDia   float64 // Diameter the tip of the mate sweeps the flank to
+	Interference bool    // The tip of the mate runs off the involute
+	Depth        float64 // How far off the involute, radially
+	// Only found on internal gears, where the tip of the pinion clashes
+	// with the tips of the internal teeth as it leaves mesh.
+	Trochoid bool
+}
+
+// Check gear g against its mate for undercut and tip interference. The tip
+// of the mate sweeps the flank of g down to the contact diameter, which must
+// stay on the involute, above the form circle.
+func checkMate(g, mate Gear, c, aw float64) Interference {
+	var i Interference
+	i.FormDia = g.GetFormCircleDia()
+	br := g.GetBaseCircleDia() / 2
+	mbr := mate.GetBaseCircleDia() / 2
+	mtr := mate.GetTipDia() / 2
+	// Distance along the line of action from the tip circle of the mate to
+	// where the line touches the base circle of g.
+	mt := math.Sqrt(math.Max(0, mtr*mtr-mbr*mbr))
+	if g.Internal {
+		// The tip of the pinion works out towards the root of the internal
+		// gear, which is involute all the way. It only has to stay clear
+		// of the root circle.
+		i.ContactDia = 2 * math.Hypot(br, c*math.Sin(aw)+mt)
+		if i.ContactDia > g.GetRootCircleDia() {
+			i.Interference = true
+			i.Depth = (i.ContactDia - g.GetRootCircleDia()) / 2
+		}
+		return i
+	}
+	i.MinTeeth = g.GetMinTeeth()
+	i.Undercut = g.IsUndercut()
+	l := c*math.Sin(aw) - mt
+	// Nearest the tip of the mate comes to the centre of g.
+	reach := c - mtr
+	if mate.Internal {
+		// Both base circles are on the same side of the line of action.
+		l = mt - c*math.Sin(aw)
+		reach = mtr - c
+	}
+	if l > 0 {
+		i.ContactDia = 2 * math.Hypot(br, l)
+	} else {
+		// The tip of the mate runs past the interference point, where the
+		// line of action touches the base circle, and sweeps on down
+		// towards the root as far as the line of centres.
+		i.ContactDia = 2 * reach
+	}
+	if l <= 0 || i.ContactDia < i.FormDia {
+		i.Interference = true
+		i.Depth = (i.FormDia - i.ContactDia) / 2
+	}
+	return i
+}
+
+// Report whether the tip of pinion g1 clashes with the tips of internal gear
+// g2 as the teeth leave mesh. Angles are measured at the centres of each
+// gear from the line of centres to where the two tip circles cross.
+func trochoidInterference(g1, g2 Gear, c, aw float64) bool {
+	tr1 := g1.GetTipDia() / 2
+	tr2 := g2.GetTipDia() / 2
+	aa1 := math.Acos(g1.GetBaseCircleDia() / 2 / tr1)
+	aa2 := math.Acos(math.Min(1, g2.GetBaseCircleDia()/2/tr2))
+	theta1 := math.Acos((tr2*tr2-tr1*tr1-c*c)/(2*c*tr1)) + involute(aa1) -
+		involute(aw)
+	theta2 := math.Acos((c*c + tr2*tr2 - tr1*tr1) / (2 * c * tr2))
+	return theta1*float64(g1.N)/float64(g2.N)+involute(aw)-involute(aa2) <
+		theta2
+}
+
+// Check a pair of gears, meshing at their working centre distance, for
+// undercut and tip interference. The result for each gear is returned.
+func CheckInterference(g1, g2 Gear) (Interference, Interference) {
+	c := WorkingCentreDistance(g1, g2)
+	aw := OperatingPressureAngle(g1, g2) * DegToRad
+	i1, i2 := checkMate(g1, g2, c, aw), checkMate(g2, g1, c, aw)
+	if g2.Internal {
+		i2.Trochoid = trochoidInterference(g1, g2, c, aw)
+	}
+	return i1, i2
+}
+
+// Return a description of each problem found, naming the gear as name.
+func (i Interference) Warnings(name string) []string {
+	var retval []string
+	if i.Undercut {
+		retval = append(retval, fmt.Sprintf(
+			"%s is undercut, at least %d teeth are needed to avoid it", name,
+			i.MinTeeth))
+	}
+	if i.Interference {
+		retval = append(retval, fmt.Sprintf(
+			"%s has tip interference, mate reaches %.3f mm past the end of "+
+				"the involute (contact dia %.3f, form dia %.3f)", name,
+			i.Depth, i.ContactDia, i.FormDia))
+	}
+	if i.Trochoid {
+		retval = append(retval, fmt.Sprintf(
+			"%s has trochoid interference, its tips clash with the tips of "+
+				"the pinion as they leave mesh", name))
+	}
+	return retval
+}
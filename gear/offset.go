@@ -0,0 +1,354 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"math"
+	"sort"
+)
+
+// Return the outlines that run d mm to the right of the outline, with arcs
+// broken into chords that stray no further than tol. A positive d grows an
+// outline that runs anticlockwise and shrinks one that runs clockwise, so it
+// adds material to a part made of contours as given by Contours, and a
+// negative d takes it away. Corners the offset opens up are rounded. Where
+// the offset would cross itself, such as in a root too narrow for it, the
+// loops are cut away, and it may leave more than one outline or none at all.
+// Each outline returned is a single polyline.
+func (o Outline) Offset(d, tol float64) []Outline {
+	pts := dedupe(o.Polygon(tol))
+	if len(pts) < 3 {
+		return nil
+	}
+	if d == 0 {
+		return []Outline{polyline(pts)}
+	}
+	raw := rawOffset(pts, d, tol)
+	pieces := splitAtCrossings(raw)
+	// Keep the pieces that are as far from the outline as they should be.
+	// Anywhere closer is inside a loop where the offset crossed itself.
+	near := newSegmentGrid(pts, math.Abs(d))
+	limit := math.Abs(d) - 1e-7
+	var kept [][]Point
+	for _, p := range pieces {
+		mid := Point{(p[0].X + p[1].X) / 2, (p[0].Y + p[1].Y) / 2}
+		if near.within(mid, limit) {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	var retval []Outline
+	for _, loop := range chain(kept) {
+		if len(loop) >= 3 {
+			retval = append(retval, polyline(loop))
+		}
+	}
+	return retval
+}
+
+// How far the contours grown for the kerf may stray from the true curves.
+const kerfTolerance = 0.001
+
+// Return the contours, as given by Contours, each grown by half of k all
+// round. A cutter that burns away k mm along the line then leaves the part
+// as designed. A hole the kerf closes up is dropped.
+func kerf(cs []Outline, k float64) []Outline {
+	if k == 0 {
+		return cs
+	}
+	var retval []Outline
+	for _, c := range cs {
+		retval = append(retval, c.Offset(k/2, kerfTolerance)...)
+	}
+	return retval
+}
+
+// Return the closed polyline through pts as an outline of one segment.
+func polyline(pts []Point) Outline {
+	p := append(append([]Point{}, pts...), pts[0])
+	return Outline{{Kind: Line, Points: p}}
+}
+
+// Return the polygon with any repeated points taken out.
+func dedupe(pts []Point) []Point {
+	var retval []Point
+	for _, p := range pts {
+		if len(retval) == 0 || p != retval[len(retval)-1] {
+			retval = append(retval, p)
+		}
+	}
+	for len(retval) > 1 && retval[0] == retval[len(retval)-1] {
+		retval = retval[:len(retval)-1]
+	}
+	return retval
+}
+
+// Return the polygon with each edge moved d to its right and joined to the
+// next. Where the offset edges open apart they are joined by an arc about
+// the corner, with chords that lie outside the true arc so that no part of
+// it comes closer than d. Where they overlap they are cut back to where they
+// cross, or if they are too short to cross they are joined through the
+// corner itself, which leaves a loop for splitAtCrossings to find.
+func rawOffset(pts []Point, d, tol float64) []Point {
+	n := len(pts)
+	segs := make([][2]Point, n)
+	normals := make([]Point, n)
+	for i, a := range pts {
+		b := pts[(i+1)%n]
+		l := math.Hypot(b.X-a.X, b.Y-a.Y)
+		nm := Point{(b.Y - a.Y) / l * d, -(b.X - a.X) / l * d}
+		normals[i] = nm
+		segs[i] = [2]Point{{a.X + nm.X, a.Y + nm.Y}, {b.X + nm.X, b.Y + nm.Y}}
+	}
+	// How each edge joins the next: round a corner that opens, or cut back
+	// those that cross.
+	turns := make([]float64, n)
+	for i := range pts {
+		a, b, c := pts[i], pts[(i+1)%n], pts[(i+2)%n]
+		turns[i] = (b.X-a.X)*(c.Y-b.Y) - (b.Y-a.Y)*(c.X-b.X)
+		if d*turns[i] < 0 {
+			j := (i + 1) % n
+			if x, ok := crossing(segs[i][0], segs[i][1], segs[j][0],
+				segs[j][1]); ok {
+				segs[i][1], segs[j][0] = x, x
+			}
+		}
+	}
+	r := math.Abs(d)
+	step := math.Pi / 2
+	if tol < r {
+		step = 2 * math.Acos(1-tol/r)
+	}
+	var retval []Point
+	for i := range pts {
+		j := (i + 1) % n
+		b := pts[j]
+		retval = append(retval, segs[i][0], segs[i][1])
+		switch {
+		case d*turns[i] > 0:
+			// Go round the corner from one normal to the next.
+			n0, n1 := normals[i], normals[j]
+			a0 := math.Atan2(n0.Y, n0.X)
+			sweep := math.Atan2(n1.Y, n1.X) - a0
+			for sweep > math.Pi {
+				sweep -= 2 * math.Pi
+			}
+			for sweep < -math.Pi {
+				sweep += 2 * math.Pi
+			}
+			k := math.Max(1, math.Ceil(math.Abs(sweep)/step))
+			h := sweep / k
+			rr := r / math.Cos(h/2)
+			for m := 0.5; m < k; m++ {
+				ang := a0 + h*m
+				retval = append(retval, Point{b.X + rr*math.Cos(ang),
+					b.Y + rr*math.Sin(ang)})
+			}
+		case segs[i][1] != segs[j][0]:
+			retval = append(retval, b)
+		}
+	}
+	return dedupe(retval)
+}
+
+// Return the crossing point of segments p1-p2 and q1-q2 and true, if they
+// cross at a point inside both.
+func crossing(p1, p2, q1, q2 Point) (Point, bool) {
+	rx, ry := p2.X-p1.X, p2.Y-p1.Y
+	sx, sy := q2.X-q1.X, q2.Y-q1.Y
+	den := rx*sy - ry*sx
+	if den == 0 {
+		return Point{}, false
+	}
+	t := ((q1.X-p1.X)*sy - (q1.Y-p1.Y)*sx) / den
+	u := ((q1.X-p1.X)*ry - (q1.Y-p1.Y)*rx) / den
+	if t <= 0 || t >= 1 || u <= 0 || u >= 1 {
+		return Point{}, false
+	}
+	return Point{p1.X + t*rx, p1.Y + t*ry}, true
+}
+
+// Split the closed polygon into pieces, each a single straight segment,
+// wherever it crosses itself. Both pieces that meet at a crossing share the
+// very same point.
+func splitAtCrossings(pts []Point) [][]Point {
+	n := len(pts)
+	type cut struct {
+		t float64
+		p Point
+	}
+	cuts := make([][]cut, n)
+	// Sweep along x, testing each segment against those that overlap it.
+	order := make([]int, n)
+	lo := make([]float64, n)
+	for i := range order {
+		order[i] = i
+		lo[i] = math.Min(pts[i].X, pts[(i+1)%n].X)
+	}
+	sort.Slice(order, func(a, b int) bool { return lo[order[a]] < lo[order[b]] })
+	for a, i := range order {
+		p1, p2 := pts[i], pts[(i+1)%n]
+		hi := math.Max(p1.X, p2.X)
+		for _, j := range order[a+1:] {
+			if lo[j] > hi {
+				break
+			}
+			if j == (i+1)%n || i == (j+1)%n {
+				continue
+			}
+			q1, q2 := pts[j], pts[(j+1)%n]
+			if math.Max(q1.Y, q2.Y) < math.Min(p1.Y, p2.Y) ||
+				math.Min(q1.Y, q2.Y) > math.Max(p1.Y, p2.Y) {
+				continue
+			}
+			if x, ok := crossing(p1, p2, q1, q2); ok {
+				cuts[i] = append(cuts[i], cut{along(p1, p2, x), x})
+				cuts[j] = append(cuts[j], cut{along(q1, q2, x), x})
+			}
+		}
+	}
+	var retval [][]Point
+	for i := range pts {
+		c := cuts[i]
+		sort.Slice(c, func(a, b int) bool { return c[a].t < c[b].t })
+		prev := pts[i]
+		for _, k := range c {
+			if k.p != prev {
+				retval = append(retval, []Point{prev, k.p})
+				prev = k.p
+			}
+		}
+		if end := pts[(i+1)%n]; end != prev {
+			retval = append(retval, []Point{prev, end})
+		}
+	}
+	return retval
+}
+
+// Return how far x lies along the segment from p1 to p2, as a fraction.
+func along(p1, p2, x Point) float64 {
+	if math.Abs(p2.X-p1.X) > math.Abs(p2.Y-p1.Y) {
+		return (x.X - p1.X) / (p2.X - p1.X)
+	}
+	return (x.Y - p1.Y) / (p2.Y - p1.Y)
+}
+
+// Join pieces end to start into closed loops. A piece whose end meets no
+// other piece is dropped along with the rest of its chain.
+func chain(pieces [][]Point) [][]Point {
+	starts := map[Point][]int{}
+	for i, p := range pieces {
+		starts[p[0]] = append(starts[p[0]], i)
+	}
+	used := make([]bool, len(pieces))
+	var retval [][]Point
+	for i := range pieces {
+		if used[i] {
+			continue
+		}
+		var loop []Point
+		j := i
+		for !used[j] {
+			used[j] = true
+			loop = append(loop, pieces[j][0])
+			next := -1
+			for _, k := range starts[pieces[j][1]] {
+				if !used[k] || k == i {
+					next = k
+					break
+				}
+			}
+			if next < 0 {
+				loop = nil
+				break
+			}
+			if next == i {
+				break
+			}
+			j = next
+		}
+		if loop != nil {
+			retval = append(retval, loop)
+		}
+	}
+	return retval
+}
+
+// A grid of the edges of a closed polygon, to find quickly whether a point
+// is near any of them.
+type segmentGrid struct {
+	pts   []Point
+	size  float64
+	cells map[[2]int][]int
+}
+
+// Return a grid of the edges of pts, to search for points within r of
+// them. The cells are the mean length of an edge, so each edge covers only
+// a few, and no smaller than r, so a search covers only a few.
+func newSegmentGrid(pts []Point, r float64) segmentGrid {
+	n := len(pts)
+	size := 0.0
+	for i, a := range pts {
+		b := pts[(i+1)%n]
+		size += math.Hypot(b.X-a.X, b.Y-a.Y)
+	}
+	size = math.Max(size/float64(n), r)
+	g := segmentGrid{pts, size, map[[2]int][]int{}}
+	for i, a := range pts {
+		b := pts[(i+1)%n]
+		x0, y0 := g.cell(Point{math.Min(a.X, b.X), math.Min(a.Y, b.Y)})
+		x1, y1 := g.cell(Point{math.Max(a.X, b.X), math.Max(a.Y, b.Y)})
+		for x := x0; x <= x1; x++ {
+			for y := y0; y <= y1; y++ {
+				g.cells[[2]int{x, y}] = append(g.cells[[2]int{x, y}], i)
+			}
+		}
+	}
+	return g
+}
+
+// Return the cell that holds p.
+func (g segmentGrid) cell(p Point) (int, int) {
+	return int(math.Floor(p.X / g.size)), int(math.Floor(p.Y / g.size))
+}
+
+// Report whether p is closer than r to any edge.
+func (g segmentGrid) within(p Point, r float64) bool {
+	n := len(g.pts)
+	x0, y0 := g.cell(Point{p.X - r, p.Y - r})
+	x1, y1 := g.cell(Point{p.X + r, p.Y + r})
+	for x := x0; x <= x1; x++ {
+		for y := y0; y <= y1; y++ {
+			for _, i := range g.cells[[2]int{x, y}] {
+				if segmentDistance(p, g.pts[i], g.pts[(i+1)%n]) < r {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Return the distance from p to the segment a-b.
+func segmentDistance(p, a, b Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/l))
+	}
+	return math.Hypot(p.X-a.X-t*dx, p.Y-a.Y-t*dy)
+}
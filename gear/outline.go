@@ -0,0 +1,411 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"math"
+)
+
+// Number of points used to approximate each involute flank.
+const involutePoints = 40
+
+// The kinds of segment that make up an outline.
+type SegmentKind int
+
+const (
+	Line     SegmentKind = iota // Straight line between two points
+	Arc                         // Circular arc about Centre
+	Involute                    // Polyline following an involute flank
+	Fillet                      // Polyline following a trochoidal root fillet
+)
+
+// One piece of an outline. Every kind of segment starts at its first point
+// and ends at its last, so an arc holds just its two end points.
+type Segment struct {
+	Kind      SegmentKind
+	Points    []Point
+	Centre    Point   // Centre of an arc
+	Radius    float64 // Radius of an arc
+	Clockwise bool    // Direction of an arc
+}
+
+// A closed outline made of segments joined end to end. The last segment
+// ends where the first one starts.
+type Outline []Segment
+
+// Return the first point of the segment.
+func (s Segment) Start() Point {
+	return s.Points[0]
+}
+
+// Return the last point of the segment.
+func (s Segment) End() Point {
+	return s.Points[len(s.Points)-1]
+}
+
+// Return the angle swept by an arc, in radians. It is positive for an
+// anticlockwise arc and negative for a clockwise one.
+func (s Segment) Sweep() float64 {
+	a0 := math.Atan2(s.Start().Y-s.Centre.Y, s.Start().X-s.Centre.X)
+	a1 := math.Atan2(s.End().Y-s.Centre.Y, s.End().X-s.Centre.X)
+	sweep := a1 - a0
+	if s.Clockwise {
+		for sweep >= 0 {
+			sweep -= 2 * math.Pi
+		}
+	} else {
+		for sweep <= 0 {
+			sweep += 2 * math.Pi
+		}
+	}
+	return sweep
+}
+
+// Return the segment rotated about the origin by angle ang (radians).
+func (s Segment) Rotate(ang float64) Segment {
+	r := s
+	r.Points = make([]Point, len(s.Points))
+	for i, p := range s.Points {
+		r.Points[i] = p.Rotate(ang)
+	}
+	r.Centre = s.Centre.Rotate(ang)
+	return r
+}
+
+// Return the segment moved by dx, dy.
+func (s Segment) Translate(dx, dy float64) Segment {
+	r := s
+	r.Points = make([]Point, len(s.Points))
+	for i, p := range s.Points {
+		r.Points[i] = Point{p.X + dx, p.Y + dy}
+	}
+	r.Centre = Point{s.Centre.X + dx, s.Centre.Y + dy}
+	return r
+}
+
+// Return the segment running in the opposite direction.
+func (s Segment) Reverse() Segment {
+	r := s
+	r.Points = make([]Point, len(s.Points))
+	for i, p := range s.Points {
+		r.Points[len(s.Points)-1-i] = p
+	}
+	if s.Kind == Arc {
+		r.Clockwise = !s.Clockwise
+	}
+	return r
+}
+
+// Return the points along the segment, with arcs broken into chords that
+// stray no further than tol from the true arc. The first point is left out
+// so that joined segments do not repeat their shared points.
+func (s Segment) flatten(tol float64) []Point {
+	if s.Kind != Arc {
+		return s.Points[1:]
+	}
+	sweep := s.Sweep()
+	step := math.Pi / 8
+	if tol < s.Radius {
+		step = math.Min(step, 2*math.Acos(1-tol/s.Radius))
+	}
+	n := int(math.Ceil(math.Abs(sweep) / step))
+	start := Point{s.Start().X - s.Centre.X, s.Start().Y - s.Centre.Y}
+	var pts []Point
+	for i := 1; i < n; i++ {
+		p := start.Rotate(sweep * float64(i) / float64(n))
+		pts = append(pts, Point{p.X + s.Centre.X, p.Y + s.Centre.Y})
+	}
+	return append(pts, s.End())
+}
+
+// Return the outline rotated about the origin by angle ang (radians).
+func (o Outline) Rotate(ang float64) Outline {
+	r := make(Outline, len(o))
+	for i, s := range o {
+		r[i] = s.Rotate(ang)
+	}
+	return r
+}
+
+// Return the outline moved by dx, dy.
+func (o Outline) Translate(dx, dy float64) Outline {
+	r := make(Outline, len(o))
+	for i, s := range o {
+		r[i] = s.Translate(dx, dy)
+	}
+	return r
+}
+
+// Return the outline running in the opposite direction.
+func (o Outline) Reverse() Outline {
+	r := make(Outline, len(o))
+	for i, s := range o {
+		r[len(o)-1-i] = s.Reverse()
+	}
+	return r
+}
+
+// Return the outline reflected in the x axis. It is reversed as well, so it
+// runs the same way round as before.
+func (o Outline) Mirror() Outline {
+	r := make(Outline, len(o))
+	for i, s := range o {
+		m := s
+		m.Points = make([]Point, len(s.Points))
+		for j, p := range s.Points {
+			m.Points[j] = Point{p.X, -p.Y}
+		}
+		m.Centre = Point{s.Centre.X, -s.Centre.Y}
+		m.Clockwise = !s.Clockwise
+		r[i] = m
+	}
+	return r.Reverse()
+}
+
+// Return the corners of the smallest box, square to the axes, that holds
+// the outline.
+func (o Outline) Bounds() (Point, Point) {
+	min := Point{math.Inf(1), math.Inf(1)}
+	max := Point{math.Inf(-1), math.Inf(-1)}
+	for _, p := range o.Polygon(0.001) {
+		min = Point{math.Min(min.X, p.X), math.Min(min.Y, p.Y)}
+		max = Point{math.Max(max.X, p.X), math.Max(max.Y, p.Y)}
+	}
+	return min, max
+}
+
+// Return a full circle of radius r about the origin as an outline of two
+// half circles, running clockwise if cw is set.
+func circleOutline(r float64, cw bool) Outline {
+	a, b := Point{r, 0}, Point{-r, 0}
+	return Outline{
+		{Kind: Arc, Points: []Point{a, b}, Radius: r, Clockwise: cw},
+		{Kind: Arc, Points: []Point{b, a}, Radius: r, Clockwise: cw},
+	}
+}
+
+// Return the outline as a closed polygon. Arcs are broken into chords that
+// stray no further than tol from the true arc. The first point is not
+// repeated at the end.
+func (o Outline) Polygon(tol float64) []Point {
+	var pts []Point
+	for _, s := range o {
+		pts = append(pts, s.flatten(tol)...)
+	}
+	return pts
+}
+
+// Calculate the involute angle at witch point the involute reaches the
+// requested radius
+func involuteIntersectAngle(br, r float64) float64 {
+	return math.Sqrt(math.Pow(r/br, 2) - 1)
+}
+
+// Calculate the xy cordinates of the involute at a given angle
+func xyLocation(br, ang float64) (float64, float64) {
+	x := br * (math.Cos(ang) + ang*math.Sin(ang))
+	y := br * (math.Sin(ang) - ang*math.Cos(ang))
+	return x, y
+}
+
+// Return the involute of the lower flank of the tooth centred on the positive
+// x axis, running from the form circle out to the outside circle.
+func (g Gear) GetInvoluteFlank() []Point {
+	br := g.GetBaseCircleDia() / 2
+	sr := g.GetFormCircleDia() / 2
+	or := g.GetTipDia() / 2
+	// Turn the involute so that it crosses the pitch circle half a tooth
+	// thickness below the x axis.
+	x, y := xyLocation(br, involuteIntersectAngle(br, g.Pd/2))
+	offsetAng := -math.Atan2(y, x) - g.GetAngularToothThickness()*DegToRad/2
+	// Space the points evenly in roll angle, which puts more of them where
+	// the involute is most curved.
+	a0 := involuteIntersectAngle(br, sr)
+	a1 := involuteIntersectAngle(br, or)
+	pts := make([]Point, involutePoints)
+	for i := range pts {
+		x, y := xyLocation(br, a0+(a1-a0)*float64(i)/float64(involutePoints-1))
+		pts[i] = Point{x, y}.Rotate(offsetAng)
+	}
+	return pts
+}
+
+// Return the outline of a single tooth centred on the positive x axis. It
+// runs anticlockwise from the root circle, up the lower flank, across the tip
+// and down the upper flank to the root circle again.
+func (g Gear) toothOutline() Outline {
+	var o Outline
+	fillet := g.GetRootFillet()
+	flank := g.GetInvoluteFlank()
+	// Join the fillet exactly to the start of the involute.
+	fillet[len(fillet)-1] = flank[0]
+	o = append(o, Segment{Kind: Fillet, Points: fillet})
+	o = append(o, Segment{Kind: Involute, Points: flank})
+	lowerTip := flank[len(flank)-1]
+	upperTip := Point{lowerTip.X, -lowerTip.Y}
+	if lowerTip.Y < 0 {
+		o = append(o, Segment{Kind: Arc, Points: []Point{lowerTip, upperTip},
+			Radius: g.GetTipDia() / 2})
+	}
+	// The upper flank is the mirror image of the lower flank.
+	for i := 1; i >= 0; i-- {
+		s := o[i].Reverse()
+		for j := range s.Points {
+			s.Points[j].Y = -s.Points[j].Y
+		}
+		o = append(o, s)
+	}
+	return o
+}
+
+// Return the outline of a single tooth space of an internal gear, centred on
+// the positive x axis. It runs anticlockwise from the tip circle, out along
+// the lower flank, across the root and back in along the upper flank.
+func (g Gear) spaceOutline() Outline {
+	var o Outline
+	br := g.GetBaseCircleDia() / 2
+	tr := g.GetTipDia() / 2
+	rr := g.GetRootCircleDia() / 2
+	sr := math.Max(tr, br)
+	// The flanks of the space are those of an external tooth as wide as the
+	// space, turned so they cross the pitch circle half a space from the
+	// x axis.
+	space := g.GetCircularPitch() - g.GetToothThickness()
+	offsetAng := -space/g.Pd - involute(g.A*DegToRad)
+	a0 := involuteIntersectAngle(br, sr)
+	a1 := involuteIntersectAngle(br, rr)
+	flank := make([]Point, involutePoints)
+	for i := range flank {
+		x, y := xyLocation(br, a0+(a1-a0)*float64(i)/float64(involutePoints-1))
+		flank[i] = Point{x, y}.Rotate(offsetAng)
+	}
+	if tr < br {
+		// There is no involute inside the base circle, so run straight in
+		// to the tip.
+		tip := Point{tr, 0}.Rotate(offsetAng)
+		o = append(o, Segment{Kind: Line, Points: []Point{tip, flank[0]}})
+	}
+	o = append(o, Segment{Kind: Involute, Points: flank})
+	lowerRoot := flank[len(flank)-1]
+	upperRoot := Point{lowerRoot.X, -lowerRoot.Y}
+	if lowerRoot.Y < 0 {
+		o = append(o, Segment{Kind: Arc, Points: []Point{lowerRoot, upperRoot},
+			Radius: rr})
+	}
+	// The upper flank is the mirror image of the lower flank.
+	for i := len(o) - 1; i >= 0; i-- {
+		if o[i].Kind == Arc {
+			continue
+		}
+		s := o[i].Reverse()
+		for j := range s.Points {
+			s.Points[j].Y = -s.Points[j].Y
+		}
+		o = append(o, s)
+	}
+	return o
+}
+
+// Return the closed outline of the gear, centred on the origin with the first
+// tooth centred on the positive x axis. The outline runs anticlockwise and is
+// made up of fillets, involutes, tip arcs and root arcs in mm.
+// For an internal gear the outline is of the teeth alone, with a tooth space
+// centred on the positive x axis. The rim is not included.
+func (g Gear) Outline() Outline {
+	var o Outline
+	// Repeat a tooth, joining each to the next around the root circle. An
+	// internal gear repeats the space instead, joined around the tip.
+	unit, r := g.toothOutline(), g.GetRootCircleDia()/2
+	if g.Internal {
+		unit, r = g.spaceOutline(), g.GetTipDia()/2
+	}
+	pitch := 2 * math.Pi / float64(g.N)
+	for i := 0; i < g.N; i++ {
+		t := unit.Rotate(pitch * float64(i))
+		o = append(o, t...)
+		start := t[len(t)-1].End()
+		end := unit[0].Start().Rotate(pitch * float64(i+1))
+		if start.X*end.Y-start.Y*end.X > 1e-12 {
+			o = append(o, Segment{Kind: Arc, Points: []Point{start, end},
+				Radius: r})
+		}
+	}
+	return o
+}
+
+// Return the closed contours to cut the gear out of a sheet, in the order
+// they should be cut. Holes come first and run clockwise, and the outside
+// comes last and runs anticlockwise. An internal gear has its teeth as a
+// hole inside the rim, and no bore. Any kerf is allowed for.
+func (g Gear) Contours() []Outline {
+	return kerf(g.contours(), g.Kerf)
+}
+
+// Return the contours given by Contours split into those grown from the
+// teeth, which twist along a helical gear, and the rest. Allowing for the
+// kerf can split a contour or drop it, so there may be any number of each.
+func (g Gear) SplitContours() ([]Outline, []Outline) {
+	cs := g.contours()
+	// The teeth are the last contour as designed, or the first of an
+	// internal gear.
+	teeth := len(cs) - 1
+	if g.Internal {
+		teeth = 0
+	}
+	var rest []Outline
+	for i, o := range cs {
+		if i != teeth {
+			rest = append(rest, o)
+		}
+	}
+	return kerf(cs[teeth:teeth+1], g.Kerf), kerf(rest, g.Kerf)
+}
+
+// Return the closed contours of the gear as designed, without the kerf.
+func (g Gear) contours() []Outline {
+	if g.Internal {
+		return []Outline{g.Outline().Reverse(),
+			circleOutline(g.GetRimDia()/2, false)}
+	}
+	var retval []Outline
+	for _, h := range g.Holes() {
+		retval = append(retval, h.Reverse())
+	}
+	return append(retval, g.Outline())
+}
+
+// Lay out parts, each a set of contours, from left to right along the x axis
+// with gap mm between them, so they can be cut from one sheet. The contours
+// are returned in order, each part centred on the x axis.
+func Layout(gap float64, parts ...[]Outline) []Outline {
+	var retval []Outline
+	x := 0.0
+	for _, part := range parts {
+		min := Point{math.Inf(1), math.Inf(1)}
+		max := Point{math.Inf(-1), math.Inf(-1)}
+		for _, o := range part {
+			lo, hi := o.Bounds()
+			min = Point{math.Min(min.X, lo.X), math.Min(min.Y, lo.Y)}
+			max = Point{math.Max(max.X, hi.X), math.Max(max.Y, hi.Y)}
+		}
+		for _, o := range part {
+			retval = append(retval, o.Translate(x-min.X, -(min.Y+max.Y)/2))
+		}
+		x += max.X - min.X + gap
+	}
+	return retval
+}
@@ -0,0 +1,136 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"fmt"
+	"math"
+)
+
+// Structure to hold a pair of meshing gears. G1 drives G2, which may be an
+// internal gear with G1 running inside it.
+type Pair struct {
+	G1 Gear
+	G2 Gear
+	C  float64 // Centre distance
+}
+
+// Create a pair of gears meshing at their working centre distance.
+func NewPair(g1, g2 Gear) Pair {
+	return Pair{G1: g1, G2: g2, C: WorkingCentreDistance(g1, g2)}
+}
+
+// Calculate and return the operating pressure angle at the centre distance
+// of the pair.
+func (p Pair) GetOperatingPressureAngle() float64 {
+	rb := math.Abs(p.G1.sign()*p.G1.GetBaseCircleDia()+
+		p.G2.sign()*p.G2.GetBaseCircleDia()) / 2
+	return math.Acos(rb/p.C) * RadToDeg
+}
+
+// Return the position of the centre of G2 along the x axis, taking G1 to be
+// centred on the origin. An internal G2 is centred to the left of G1, so
+// that they mesh on the right hand side.
+func (p Pair) GetOffset() float64 {
+	return p.G2.sign() * p.C
+}
+
+// Calculate and return the base pitch, the distance between adjacent teeth
+// measured along the line of action.
+func (p Pair) GetBasePitch() float64 {
+	return math.Pi * p.G1.GetBaseCircleDia() / float64(p.G1.N)
+}
+
+// Calculate and return the length of the line of action between the points
+// where it touches the two base circles.
+func (p Pair) GetLineOfAction() float64 {
+	return p.C * math.Sin(p.GetOperatingPressureAngle()*DegToRad)
+}
+
+// Return the distance along the line of action from the pitch point to
+// where it crosses the tip circle of g.
+func (p Pair) tipToPitchPoint(g Gear) float64 {
+	br := g.GetBaseCircleDia() / 2
+	tr := g.GetTipDia() / 2
+	aw := p.GetOperatingPressureAngle() * DegToRad
+	return g.sign() * (math.Sqrt(math.Max(0, tr*tr-br*br)) - br*math.Tan(aw))
+}
+
+// Calculate and return the length of the path of approach. Contact starts
+// where the tip of the driven gear meets the flank of the driver.
+func (p Pair) GetApproach() float64 {
+	return p.tipToPitchPoint(p.G2)
+}
+
+// Calculate and return the length of the path of recess. Contact ends where
+// the tip of the driver leaves the flank of the driven gear.
+func (p Pair) GetRecess() float64 {
+	return p.tipToPitchPoint(p.G1)
+}
+
+// Calculate and return the length of the path of contact. It can be no
+// longer than the line of action.
+func (p Pair) GetPathOfContact() float64 {
+	return math.Min(p.GetApproach()+p.GetRecess(), p.GetLineOfAction())
+}
+
+// Calculate and return the transverse contact ratio, the average number of
+// teeth in contact. Below 1.0 the gears will not run smoothly.
+func (p Pair) GetContactRatio() float64 {
+	return p.GetPathOfContact() / p.GetBasePitch()
+}
+
+// Return the rotation in degrees of each gear so that the teeth mesh, with
+// the gears placed as given by GetOffset. rotfrac is the percentage of one
+// tooth that both gears are turned through.
+func (p Pair) GetRotations(rotfrac float64) (float64, float64) {
+	rot1 := (rotfrac / 100) * (360 / float64(p.G1.N))
+	rot2 := 0.0
+	// A tooth on G1 points at G2, so G2 needs a space facing it. An
+	// internal gear already has a space on its positive x axis, and turns
+	// the same way as G1.
+	if !p.G2.Internal && p.G2.N%2 == 0 {
+		rot2 = 180.0 / float64(p.G2.N)
+	}
+	rot2 -= p.G2.sign() * (rotfrac / 100) * (360 / float64(p.G2.N))
+	return rot1, rot2
+}
+
+// Spit out a load of text that describes this pair of gears.
+func (p Pair) String() string {
+	var retval string
+	retval += fmt.Sprintf("Centre Distance:         %.3f\n", p.C)
+	retval += fmt.Sprintf("Ratio:                   %.3f\n",
+		float64(p.G2.N)/float64(p.G1.N))
+	retval += fmt.Sprintf("Operating Press. Angle:  %.3f\n",
+		p.GetOperatingPressureAngle())
+	retval += fmt.Sprintf("Line of Action:          %.3f\n", p.GetLineOfAction())
+	retval += fmt.Sprintf("Path of Approach:        %.3f\n", p.GetApproach())
+	retval += fmt.Sprintf("Path of Recess:          %.3f\n", p.GetRecess())
+	retval += fmt.Sprintf("Path of Contact:         %.3f\n",
+		p.GetPathOfContact())
+	retval += fmt.Sprintf("Base Pitch:              %.3f\n", p.GetBasePitch())
+	retval += fmt.Sprintf("Contact Ratio:           %.3f\n",
+		p.GetContactRatio())
+	if p.G1.Helix != 0 {
+		retval += fmt.Sprintf("Axial Contact Ratio:     %.3f\n",
+			p.GetAxialContactRatio())
+		retval += fmt.Sprintf("Total Contact Ratio:     %.3f\n",
+			p.GetTotalContactRatio())
+	}
+	return retval
+}
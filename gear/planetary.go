@@ -0,0 +1,270 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"fmt"
+	"math"
+)
+
+// The members of a planetary set that can be held still.
+type Member int
+
+const (
+	RingMember    Member = iota // The ring gear
+	CarrierMember               // The carrier that holds the planets
+	SunMember                   // The sun gear
+)
+
+var memberNames = []string{"ring", "carrier", "sun"}
+
+// Return the name of the member, as given to ParseMember.
+func (m Member) String() string {
+	if m < 0 || int(m) >= len(memberNames) {
+		return fmt.Sprintf("Member(%d)", int(m))
+	}
+	return memberNames[m]
+}
+
+// Return the member with the given name.
+func ParseMember(s string) (Member, error) {
+	for i, name := range memberNames {
+		if name == s {
+			return Member(i), nil
+		}
+	}
+	return RingMember, fmt.Errorf("unknown member %q, want ring, carrier or "+
+		"sun", s)
+}
+
+// A planetary, or epicyclic, set. The sun sits on the origin inside the
+// ring, and the planets run between the two, spaced evenly around a carrier.
+type Planetary struct {
+	Sun     Gear
+	Planet  Gear
+	Ring    Gear
+	Planets int // Number of planets
+}
+
+// Create a planetary set of n planets between sun and ring. The planets are
+// made like the sun, unshifted and with the teeth to fill the gap between
+// the sun and an unshifted ring. The ring is made internal, and helical
+// planets and ring take the opposite hand to the sun.
+func NewPlanetary(sun, ring Gear, n int) Planetary {
+	planet := sun
+	planet.N = (ring.N - sun.N) / 2
+	planet.Pd = sun.Pd * float64(planet.N) / float64(sun.N)
+	planet.X = 0
+	planet.Bore = Bore{}
+	planet.Web = Web{}
+	planet.Hand = sun.Hand.Opposite()
+	ring.Internal = true
+	ring.Hand = planet.Hand
+	return Planetary{Sun: sun, Planet: planet, Ring: ring, Planets: n}
+}
+
+// Calculate and return the distance of the planet centres from the centre
+// of the sun.
+func (p Planetary) GetCentreDistance() float64 {
+	return WorkingCentreDistance(p.Sun, p.Planet)
+}
+
+// Calculate and return the gap between the tips of neighbouring planets,
+// negative if they overlap.
+func (p Planetary) GetPlanetClearance() float64 {
+	if p.Planets < 2 {
+		return math.Inf(1)
+	}
+	return 2*p.GetCentreDistance()*math.Sin(math.Pi/float64(p.Planets)) -
+		p.Planet.GetTipDia()
+}
+
+// Return the sun and a planet in mesh.
+func (p Planetary) SunPair() Pair {
+	return NewPair(p.Sun, p.Planet)
+}
+
+// Return a planet and the ring in mesh.
+func (p Planetary) RingPair() Pair {
+	return NewPair(p.Planet, p.Ring)
+}
+
+// Check that the set can be put together, and return an error for the
+// first problem found. The planets must be the same distance from the sun
+// and the ring, the teeth of the sun and ring together must share evenly
+// between the planets so that each meshes with both, and neighbouring
+// planets must not touch.
+func (p Planetary) Check() error {
+	if p.Planets < 1 {
+		return fmt.Errorf("a planetary set needs at least one planet")
+	}
+	if p.Planet.N < 1 {
+		return fmt.Errorf("the ring needs at least two more teeth than the " +
+			"sun to leave room for the planets")
+	}
+	for _, g := range []struct {
+		name string
+		g    Gear
+	}{{"sun", p.Sun}, {"planet", p.Planet}, {"ring", p.Ring}} {
+		if err := g.g.Check(); err != nil {
+			return fmt.Errorf("%s: %w", g.name, err)
+		}
+	}
+	if p.Sun.Internal || p.Planet.Internal || !p.Ring.Internal {
+		return fmt.Errorf("a planetary set needs external sun and planets " +
+			"and an internal ring")
+	}
+	if math.Abs(p.Sun.GetModule()-p.Ring.GetModule()) > 1e-9 ||
+		math.Abs(p.Sun.A-p.Ring.A) > 1e-9 {
+		return fmt.Errorf("the sun and ring have different modules or " +
+			"pressure angles and cannot mesh")
+	}
+	cs, cr := p.GetCentreDistance(), WorkingCentreDistance(p.Planet, p.Ring)
+	if math.Abs(cs-cr) > 1e-6 {
+		return fmt.Errorf("the sun and ring are not coaxial, the planets "+
+			"mesh %.3f mm from the sun but %.3f mm from the ring", cs, cr)
+	}
+	if (p.Sun.N+p.Ring.N)%p.Planets != 0 {
+		return fmt.Errorf("%d planets cannot be spaced evenly, the %d teeth "+
+			"of the sun and ring together must be a multiple of the number "+
+			"of planets", p.Planets, p.Sun.N+p.Ring.N)
+	}
+	if c := p.GetPlanetClearance(); c <= 0 {
+		return fmt.Errorf("neighbouring planets overlap by %.3f mm", -c)
+	}
+	if err := p.SunPair().CheckHelix(); err != nil {
+		return fmt.Errorf("sun and planet: %v", err)
+	}
+	if err := p.RingPair().CheckHelix(); err != nil {
+		return fmt.Errorf("planet and ring: %v", err)
+	}
+	return nil
+}
+
+// Return the member that drives the set when m is held, and the member it
+// drives. The sun drives unless it is held, when the ring drives, and the
+// carrier is driven unless it is held, when the ring is driven.
+func (m Member) Drive() (Member, Member) {
+	switch m {
+	case SunMember:
+		return RingMember, CarrierMember
+	case CarrierMember:
+		return SunMember, RingMember
+	}
+	return SunMember, CarrierMember
+}
+
+// Calculate and return the turns of the sun, carrier and ring for each turn
+// of the input when member fixed is held, negative for the opposite way.
+func (p Planetary) GetSpeeds(fixed Member) (float64, float64, float64) {
+	zs, zr := float64(p.Sun.N), float64(p.Ring.N)
+	switch fixed {
+	case SunMember:
+		return 0, zr / (zs + zr), 1
+	case CarrierMember:
+		return 1, 0, -zs / zr
+	}
+	return 1, zs / (zs + zr), 0
+}
+
+// Calculate and return the ratio of the set with member fixed held, the
+// turns of the input for each turn of the output. It is negative if the two
+// turn in opposite directions.
+func (p Planetary) GetRatio(fixed Member) float64 {
+	s, c, r := p.GetSpeeds(fixed)
+	speeds := map[Member]float64{SunMember: s, CarrierMember: c, RingMember: r}
+	in, out := fixed.Drive()
+	return speeds[in] / speeds[out]
+}
+
+// Where each part of a planetary set sits and how it turns. The Rot of the
+// carrier is the direction of the first planet, and each Speed is in turns
+// for each turn of the input.
+type PlanetaryLayout struct {
+	Sun     Placement
+	Carrier Placement
+	Ring    Placement
+	Planets []Placement
+}
+
+// Return where each gear of the set sits and the rotation of each so the
+// teeth mesh at every contact, with member fixed held. rotfrac is the
+// percentage of one tooth that the input is turned through. The first
+// planet starts on the x axis.
+func (p Planetary) Layout(fixed Member, rotfrac float64) (PlanetaryLayout,
+	error) {
+	var l PlanetaryLayout
+	if err := p.Check(); err != nil {
+		return l, err
+	}
+	s, c, r := p.GetSpeeds(fixed)
+	in, _ := fixed.Drive()
+	n := p.Sun.N
+	if in == RingMember {
+		n = p.Ring.N
+	}
+	// Turns of the input for rotfrac percent of one of its teeth.
+	turns := rotfrac / 100 / float64(n)
+	carrier := c * turns * 360
+	// Mesh the set as a train: the sun drives each planet, and the first
+	// planet drives the ring.
+	t := Train{Gears: []TrainGear{{Gear: p.Sun}}}
+	for i := 0; i < p.Planets; i++ {
+		t.Gears = append(t.Gears, TrainGear{Gear: p.Planet, From: 0,
+			Angle: carrier + 360*float64(i)/float64(p.Planets)})
+	}
+	t.Gears = append(t.Gears, TrainGear{Gear: p.Ring, From: 1,
+		Angle: carrier + 180})
+	// The train turns the sun by a percentage of one of its teeth.
+	ps, err := t.Layout(s * turns * float64(p.Sun.N) * 100)
+	if err != nil {
+		return l, err
+	}
+	// Each planet turns on its own axis against the sun as the carrier
+	// goes round.
+	planet := c - (s-c)*float64(p.Sun.N)/float64(p.Planet.N)
+	l.Sun = Placement{Rot: ps[0].Rot, Speed: s}
+	l.Carrier = Placement{Rot: carrier, Speed: c}
+	l.Ring = Placement{Centre: ps[len(ps)-1].Centre, Rot: ps[len(ps)-1].Rot,
+		Speed: r}
+	for _, pp := range ps[1 : len(ps)-1] {
+		l.Planets = append(l.Planets, Placement{Centre: pp.Centre,
+			Rot: pp.Rot, Speed: planet})
+	}
+	return l, nil
+}
+
+// Spit out a load of text that describes this planetary set.
+func (p Planetary) String() string {
+	var retval string
+	retval += fmt.Sprintf("Sun Teeth:               %d\n", p.Sun.N)
+	retval += fmt.Sprintf("Planet Teeth:            %d\n", p.Planet.N)
+	retval += fmt.Sprintf("Ring Teeth:              %d\n", p.Ring.N)
+	retval += fmt.Sprintf("Planets:                 %d\n", p.Planets)
+	retval += fmt.Sprintf("Planet Centres:          %.3f\n",
+		p.GetCentreDistance())
+	if p.Planets > 1 {
+		retval += fmt.Sprintf("Planet Clearance:        %.3f\n",
+			p.GetPlanetClearance())
+	}
+	for _, fixed := range []Member{RingMember, CarrierMember, SunMember} {
+		in, out := fixed.Drive()
+		retval += fmt.Sprintf("%-25s%.4f, %s in, %s out\n",
+			"Ratio, "+fixed.String()+" fixed:", p.GetRatio(fixed), in, out)
+	}
+	return retval
+}
@@ -0,0 +1,253 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"fmt"
+	"math"
+)
+
+// Structure to hold the supplied parameters for a straight rack. The rack
+// lies along the x axis with its pitch line on y = 0 and its teeth pointing
+// up, centred on the origin.
+type Rack struct {
+	M      float64 // Module
+	N      int     // Number of teeth
+	A      float64 // pressure angle
+	B      float64 // backlash, the width taken off each tooth at the pitch line
+	Length float64 // overall length, 0 for just the teeth
+	Back   float64 // depth of the rack below the root, 0 for a default
+	Kerf   float64 // width burnt away by the cutter, as for a gear
+}
+
+// Create a rack of n teeth to mesh with gear g. The module, pressure angle
+// and backlash are taken from the gear, the backlash angle becoming the
+// width it turns through at the pitch circle.
+func RackFor(g Gear, n int) Rack {
+	return Rack{
+		M:    g.GetModule(),
+		N:    n,
+		A:    g.A,
+		B:    g.B * DegToRad * g.Pd / 2,
+		Kerf: g.Kerf,
+	}
+}
+
+// Calculate and return the circular pitch, the distance between adjacent
+// teeth along the pitch line.
+func (r Rack) GetCircularPitch() float64 {
+	return math.Pi * r.M
+}
+
+// Calculate and return clearence, as for a gear.
+func (r Rack) GetClearence() float64 {
+	return r.A / 100.0
+}
+
+// Calculate and return the rack addendum, the height of the teeth above the
+// pitch line.
+func (r Rack) GetAddendum() float64 {
+	return r.M
+}
+
+// Calculate and return the rack dedendum, the depth of the root below the
+// pitch line.
+func (r Rack) GetDedendum() float64 {
+	return (1.0 + r.GetClearence()) * r.M
+}
+
+// Calculate and return the overall length of the rack. It is never shorter
+// than the teeth.
+func (r Rack) GetLength() float64 {
+	return math.Max(r.Length, float64(r.N)*r.GetCircularPitch())
+}
+
+// Calculate and return the depth of the rack below the root. Unless Back is
+// given, this is two modules.
+func (r Rack) GetBack() float64 {
+	if r.Back > 0 {
+		return r.Back
+	}
+	return 2 * r.M
+}
+
+// Calculate and return the width of a tooth space at the root. A cutter any
+// wider cannot reach the bottom of the flanks.
+func (r Rack) GetRootGap() float64 {
+	p := r.GetCircularPitch()
+	t := math.Tan(r.A * DegToRad)
+	return p - 2*math.Min(p/2, p/4+r.GetDedendum()*t)
+}
+
+// Return the closed outline of the rack. It runs anticlockwise along the
+// back, up the right hand end, back along the teeth and down the left hand
+// end. Any length beyond the teeth is split between the two ends at the
+// root.
+func (r Rack) Outline() Outline {
+	p := r.GetCircularPitch()
+	ha := r.GetAddendum()
+	hf := r.GetDedendum()
+	t := math.Tan(r.A * DegToRad)
+	// Half the width of a tooth at the tip and at the root, less half the
+	// backlash, with the root no wider than the pitch.
+	w := p/4 - r.B/2
+	wt := math.Max(0, w-ha*t)
+	wr := math.Min(p/2, w+hf*t)
+	l := r.GetLength() / 2
+	bottom := -hf - r.GetBack()
+	// Run along the teeth from left to right.
+	pts := []Point{{-l, -hf}}
+	for i := 0; i < r.N; i++ {
+		xc := (float64(i) - float64(r.N-1)/2) * p
+		pts = append(pts, Point{xc - wr, -hf}, Point{xc - wt, ha},
+			Point{xc + wt, ha}, Point{xc + wr, -hf})
+	}
+	pts = append(pts, Point{l, -hf})
+	o := Outline{
+		{Kind: Line, Points: []Point{{-l, bottom}, {l, bottom}}},
+		{Kind: Line, Points: []Point{{l, bottom}, {l, -hf}}},
+	}
+	for i := len(pts) - 1; i > 0; i-- {
+		if pts[i] != pts[i-1] {
+			o = append(o, Segment{Kind: Line, Points: []Point{pts[i], pts[i-1]}})
+		}
+	}
+	return append(o, Segment{Kind: Line, Points: []Point{{-l, -hf},
+		{-l, bottom}}})
+}
+
+// Return the closed contours to cut the rack out of a sheet, allowing for
+// any kerf.
+func (r Rack) Contours() []Outline {
+	return kerf([]Outline{r.Outline()}, r.Kerf)
+}
+
+// Spit out a load of text that describes this rack.
+func (r Rack) String() string {
+	var retval string
+	retval += fmt.Sprintf("Teeth:                   %d\n", r.N)
+	retval += fmt.Sprintf("Length:                  %.3f\n", r.GetLength())
+	retval += fmt.Sprintf("Module:                  %.3f\n", r.M)
+	retval += fmt.Sprintf("Circular Pitch:          %.3f\n",
+		r.GetCircularPitch())
+	retval += fmt.Sprintf("Pressure Angle:          %.3f\n", r.A)
+	retval += fmt.Sprintf("Addendum:                %.3f\n", r.GetAddendum())
+	retval += fmt.Sprintf("Dedendum:                %.3f\n", r.GetDedendum())
+	return retval
+}
+
+// Structure to hold a pinion G meshing with rack R.
+type RackPair struct {
+	G Gear
+	R Rack
+}
+
+// Return the height of the centre of the pinion above the pitch line of the
+// rack. Profile shift moves the pinion away from the rack.
+func (p RackPair) GetCentreHeight() float64 {
+	return p.G.Pd/2 + p.G.X*p.G.GetModule()
+}
+
+// Return the rotation of the pinion in degrees and the travel of the rack in
+// mm so that the teeth mesh, with the pinion centred above the origin.
+// rotfrac is the percentage of one tooth that the pinion is turned through,
+// anticlockwise, which drives the rack to the right.
+func (p RackPair) GetRotation(rotfrac float64) (float64, float64) {
+	pitch := p.R.GetCircularPitch()
+	// Point a tooth of the pinion straight down into a space of the rack.
+	rot := -90 + (rotfrac/100)*(360/float64(p.G.N))
+	travel := (rotfrac / 100) * pitch
+	if p.R.N%2 == 1 {
+		travel += pitch / 2
+	}
+	return rot, travel
+}
+
+// Calculate and return the base pitch, the distance between adjacent teeth
+// measured along the line of action.
+func (p RackPair) GetBasePitch() float64 {
+	return p.R.GetCircularPitch() * math.Cos(p.R.A*DegToRad)
+}
+
+// Calculate and return the length of the path of approach, from where the
+// tip of the rack meets the pinion to the pitch point.
+func (p RackPair) GetApproach() float64 {
+	return (p.R.GetAddendum() - p.G.X*p.G.GetModule()) /
+		math.Sin(p.R.A*DegToRad)
+}
+
+// Calculate and return the length of the path of recess, from the pitch
+// point to where the tip of the pinion leaves the rack.
+func (p RackPair) GetRecess() float64 {
+	br := p.G.GetBaseCircleDia() / 2
+	tr := p.G.GetTipDia() / 2
+	return math.Sqrt(tr*tr-br*br) - br*math.Tan(p.R.A*DegToRad)
+}
+
+// Calculate and return the length of the path of contact.
+func (p RackPair) GetPathOfContact() float64 {
+	return p.GetApproach() + p.GetRecess()
+}
+
+// Calculate and return the contact ratio, the average number of teeth in
+// contact.
+func (p RackPair) GetContactRatio() float64 {
+	return p.GetPathOfContact() / p.GetBasePitch()
+}
+
+// Check the pinion for undercut and for interference with the tips of the
+// rack. The tip of the rack reaches down the flank of the pinion to the
+// contact diameter, which must stay above the form circle.
+func (p RackPair) CheckInterference() Interference {
+	var i Interference
+	g := p.G
+	i.MinTeeth = g.GetMinTeeth()
+	i.Undercut = g.IsUndercut()
+	i.FormDia = g.GetFormCircleDia()
+	br := g.GetBaseCircleDia() / 2
+	a := p.R.A * DegToRad
+	l := g.Pd/2*math.Sin(a) - p.GetApproach()
+	if l > 0 {
+		i.ContactDia = 2 * math.Hypot(br, l)
+	} else {
+		// The tip of the rack runs past the base circle, and sweeps on
+		// down towards the root as far as the line of centres.
+		i.ContactDia = 2 * (p.GetCentreHeight() - p.R.GetAddendum())
+	}
+	if l <= 0 || i.ContactDia < i.FormDia {
+		i.Interference = true
+		i.Depth = (i.FormDia - i.ContactDia) / 2
+	}
+	return i
+}
+
+// Spit out a load of text that describes this rack and pinion.
+func (p RackPair) String() string {
+	var retval string
+	retval += fmt.Sprintf("Centre Height:           %.3f\n",
+		p.GetCentreHeight())
+	retval += fmt.Sprintf("Travel per Turn:         %.3f\n",
+		float64(p.G.N)*p.R.GetCircularPitch())
+	retval += fmt.Sprintf("Path of Approach:        %.3f\n", p.GetApproach())
+	retval += fmt.Sprintf("Path of Recess:          %.3f\n", p.GetRecess())
+	retval += fmt.Sprintf("Path of Contact:         %.3f\n",
+		p.GetPathOfContact())
+	retval += fmt.Sprintf("Base Pitch:              %.3f\n", p.GetBasePitch())
+	retval += fmt.Sprintf("Contact Ratio:           %.3f\n",
+		p.GetContactRatio())
+	return retval
+}
@@ -0,0 +1,300 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// The least contact ratio of any stage in a search unless the limits say
+// otherwise. Below it the teeth run with too little overlap to be smooth.
+const DefaultMinContactRatio = 1.2
+
+// The limits of a search for the tooth counts of a train of spur gears with a
+// given ratio. Each stage is a pair of unshifted external gears in mesh, and
+// the stages are joined by compound gears.
+type Search struct {
+	Ratio     float64   // Turns of the first gear for each turn of the last
+	Tolerance float64   // Greatest error of the ratio, as a fraction of it
+	Modules   []float64 // Modules to try for each stage
+	MinTeeth  int       // Fewest teeth on any gear
+	MaxTeeth  int       // Most teeth on any gear
+	// Least and greatest centre distance of each stage, 0 for no limit.
+	MinCentres float64
+	MaxCentres float64
+	// Least contact ratio of each stage, 0 for DefaultMinContactRatio.
+	MinContactRatio float64
+	Stages          int     // Most stages
+	A               float64 // Pressure angle
+	Rf              float64 // Rack cutter tip radius coefficient
+	Limit           int     // Most results to return, 0 for all
+}
+
+// One stage of a train found by a search.
+type Stage struct {
+	Module       float64 `json:"module"`
+	Driver       int     `json:"driver"`
+	Driven       int     `json:"driven"`
+	Centres      float64 `json:"centres"`
+	ContactRatio float64 `json:"contact_ratio"`
+}
+
+// Calculate and return the ratio of the stage, turns of the driver for each
+// turn of the driven gear.
+func (s Stage) GetRatio() float64 {
+	return float64(s.Driven) / float64(s.Driver)
+}
+
+// Report whether each tooth of the driver meets every tooth of the driven
+// gear in turn, which it does when the tooth counts share no factor.
+func (s Stage) IsHunting() bool {
+	return gcd(s.Driver, s.Driven) == 1
+}
+
+// Return the greatest common divisor of a and b.
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// A train found by a search, with the measures it is ranked by.
+type Solution struct {
+	Stages []Stage `json:"stages"`
+	Ratio  float64 `json:"ratio"`
+	// Error of the ratio, as a fraction of the ratio searched for.
+	Error float64 `json:"error"`
+	// Every stage is hunting.
+	Hunting bool `json:"hunting"`
+	// The least contact ratio of any stage.
+	ContactRatio float64 `json:"contact_ratio"`
+	// The sum of the pitch diameters of all the gears.
+	Size float64 `json:"size"`
+}
+
+// Report whether solution a ranks before b. Every solution is within the
+// tolerance, so a hunting train ranks first, then the greater contact ratio,
+// then the smaller size, and only then the smaller error.
+func (a Solution) better(b Solution) bool {
+	if a.Hunting != b.Hunting {
+		return a.Hunting
+	}
+	if math.Abs(a.ContactRatio-b.ContactRatio) > 1e-9 {
+		return a.ContactRatio > b.ContactRatio
+	}
+	if math.Abs(a.Size-b.Size) > 1e-9 {
+		return a.Size < b.Size
+	}
+	return a.Error < b.Error
+}
+
+// Check the limits of the search, and return an error for the first problem
+// found.
+func (s Search) Check() error {
+	if !(s.Ratio > 0) || math.IsInf(s.Ratio, 0) {
+		return fmt.Errorf("the ratio to search for must be greater than 0")
+	}
+	if s.Tolerance < 0 {
+		return fmt.Errorf("the tolerance of the ratio cannot be negative")
+	}
+	if len(s.Modules) == 0 {
+		return fmt.Errorf("a search needs at least one module")
+	}
+	for _, m := range s.Modules {
+		if !(m > 0) {
+			return fmt.Errorf("module %g must be greater than 0", m)
+		}
+	}
+	if s.MinTeeth < 3 || s.MaxTeeth < s.MinTeeth {
+		return fmt.Errorf("the teeth must run from at least 3 up, not %d to "+
+			"%d", s.MinTeeth, s.MaxTeeth)
+	}
+	if s.MaxCentres > 0 && s.MaxCentres < s.MinCentres {
+		return fmt.Errorf("the centres cannot run from %.3f down to %.3f",
+			s.MinCentres, s.MaxCentres)
+	}
+	if s.MinContactRatio < 0 || math.IsInf(s.MinContactRatio, 0) ||
+		math.IsNaN(s.MinContactRatio) {
+		return fmt.Errorf("the least contact ratio cannot be %g",
+			s.MinContactRatio)
+	}
+	if s.Stages < 1 || s.Stages > 3 {
+		return fmt.Errorf("a search covers 1 to 3 stages, not %d", s.Stages)
+	}
+	return nil
+}
+
+// Return every pair of gears within the limits that can be used as a
+// stage, in order of increasing ratio. Pairs that interfere, or have less
+// than the least contact ratio, are left out.
+func (s Search) stages() []Stage {
+	var retval []Stage
+	minCR := s.MinContactRatio
+	if minCR == 0 {
+		minCR = DefaultMinContactRatio
+	}
+	for _, m := range s.Modules {
+		for n1 := s.MinTeeth; n1 <= s.MaxTeeth; n1++ {
+			for n2 := s.MinTeeth; n2 <= s.MaxTeeth; n2++ {
+				c := m * float64(n1+n2) / 2
+				if c < s.MinCentres || (s.MaxCentres > 0 && c > s.MaxCentres) {
+					continue
+				}
+				g1, g2 := FromModule(m, n1, s.A, 0), FromModule(m, n2, s.A, 0)
+				g1.Rf, g2.Rf = s.Rf, s.Rf
+				i1, i2 := CheckInterference(g1, g2)
+				if i1.Interference || i2.Interference {
+					continue
+				}
+				cr := NewPair(g1, g2).GetContactRatio()
+				if cr < minCR {
+					continue
+				}
+				retval = append(retval, Stage{Module: m, Driver: n1, Driven: n2,
+					Centres: c, ContactRatio: cr})
+			}
+		}
+	}
+	sort.SliceStable(retval, func(i, j int) bool {
+		return retval[i].GetRatio() < retval[j].GetRatio()
+	})
+	return retval
+}
+
+// Search for trains of up to s.Stages stages with the ratio searched for,
+// and return the best in ranked order. The stages of each train are in
+// order of increasing ratio, as any order gives the same overall ratio.
+func (s Search) Run() ([]Solution, error) {
+	if err := s.Check(); err != nil {
+		return nil, err
+	}
+	cs := s.stages()
+	if len(cs) == 0 {
+		return nil, nil
+	}
+	lo, hi := s.Ratio*(1-s.Tolerance), s.Ratio*(1+s.Tolerance)
+	maxr := cs[len(cs)-1].GetRatio()
+	minSize := math.Inf(1)
+	for _, st := range cs {
+		minSize = math.Min(minSize, st.Module*float64(st.Driver+st.Driven))
+	}
+	var retval []Solution
+	// Once the limit is reached, the last of the results kept, which any
+	// train must beat to be kept.
+	var worst *Solution
+	keep := func() {
+		sort.SliceStable(retval, func(i, j int) bool {
+			return retval[i].better(retval[j])
+		})
+		if s.Limit > 0 && len(retval) >= s.Limit {
+			retval = retval[:s.Limit]
+			w := retval[s.Limit-1]
+			worst = &w
+		}
+	}
+	// Report whether a train that starts with the stages chosen, and has
+	// left more to come, could rank among the results kept. More stages
+	// can only lose it hunting and contact and add to its size.
+	hopeful := func(chosen []Stage, left int) bool {
+		if worst == nil {
+			return true
+		}
+		best := s.solution(chosen)
+		best.Size += minSize * float64(left)
+		best.Error = 0
+		return best.better(*worst)
+	}
+	// Choose the stages in turn, each with a ratio no less than the one
+	// before, and the last to bring the ratio within the tolerance. A train
+	// is given up as soon as its ratio can no longer come within the
+	// tolerance, or it can no longer rank among the results kept.
+	var walk func(start int, chosen []Stage, r float64, left int)
+	walk = func(start int, chosen []Stage, r float64, left int) {
+		if left == 1 {
+			i := sort.Search(len(cs), func(i int) bool {
+				return r*cs[i].GetRatio() >= lo
+			})
+			if i < start {
+				i = start
+			}
+			for ; i < len(cs) && r*cs[i].GetRatio() <= hi; i++ {
+				// A stage of equal gears does nothing in a longer train.
+				if len(chosen) > 0 && cs[i].Driver == cs[i].Driven {
+					continue
+				}
+				stages := append(chosen[:len(chosen):len(chosen)], cs[i])
+				if !hopeful(stages, 0) {
+					continue
+				}
+				retval = append(retval, s.solution(stages))
+				if s.Limit > 0 && len(retval) >= 4*s.Limit {
+					keep()
+				}
+			}
+			return
+		}
+		for i := start; i < len(cs); i++ {
+			ri := cs[i].GetRatio()
+			if r*math.Pow(ri, float64(left)) > hi {
+				break
+			}
+			if r*ri*math.Pow(maxr, float64(left-1)) < lo ||
+				cs[i].Driver == cs[i].Driven {
+				continue
+			}
+			stages := append(chosen, cs[i])
+			if !hopeful(stages, left-1) {
+				continue
+			}
+			walk(i, stages, r*ri, left-1)
+		}
+	}
+	for n := 1; n <= s.Stages; n++ {
+		walk(0, nil, 1, n)
+	}
+	keep()
+	return retval, nil
+}
+
+// Return the solution made of the stages given.
+func (s Search) solution(stages []Stage) Solution {
+	retval := Solution{Stages: stages, Ratio: 1, Hunting: true,
+		ContactRatio: math.Inf(1)}
+	for _, st := range stages {
+		retval.Ratio *= st.GetRatio()
+		retval.Hunting = retval.Hunting && st.IsHunting()
+		retval.ContactRatio = math.Min(retval.ContactRatio, st.ContactRatio)
+		retval.Size += st.Module * float64(st.Driver+st.Driven)
+	}
+	retval.Error = math.Abs(retval.Ratio-s.Ratio) / s.Ratio
+	return retval
+}
+
+// Spit out a line of text that describes this solution.
+func (s Solution) String() string {
+	var retval string
+	for i, st := range s.Stages {
+		if i > 0 {
+			retval += ", "
+		}
+		retval += fmt.Sprintf("%d:%d m%g", st.Driver, st.Driven, st.Module)
+	}
+	return retval
+}
@@ -0,0 +1,216 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"fmt"
+	"math"
+)
+
+// A gear in a train. Each gear after the first is driven by an earlier one,
+// either in mesh with it or fixed to the same shaft.
+type TrainGear struct {
+	Gear Gear
+	// The index in the train of the gear that drives this one.
+	From int
+	// A compound gear is on the same shaft as the gear that drives it, and
+	// turns with it. Otherwise the two are in mesh.
+	Compound bool
+	// The direction of the shaft of a meshing gear from the shaft of the
+	// gear that drives it, in degrees anticlockwise from the x axis.
+	Angle float64
+}
+
+// A train of gears. The first gear drives the rest and sits on the origin.
+type Train struct {
+	Gears []TrainGear
+}
+
+// Where a gear of a train sits and how it turns.
+type Placement struct {
+	Centre Point
+	// Rotation in degrees so that the teeth mesh.
+	Rot float64
+	// Turns of the gear for each turn of the first gear, negative if it
+	// turns the other way.
+	Speed float64
+}
+
+// Check the train for gears that cannot be put together, and return an error
+// for the first found.
+func (t Train) Check() error {
+	if len(t.Gears) == 0 {
+		return fmt.Errorf("a train needs at least one gear")
+	}
+	for i, tg := range t.Gears {
+		if err := tg.Gear.Check(); err != nil {
+			return fmt.Errorf("gear %d: %w", i+1, err)
+		}
+	}
+	for i, tg := range t.Gears[1:] {
+		i++
+		if tg.From < 0 || tg.From >= i {
+			return fmt.Errorf("gear %d must be driven by an earlier gear, "+
+				"not gear %d", i+1, tg.From+1)
+		}
+		if tg.Compound {
+			continue
+		}
+		d := t.Gears[tg.From].Gear
+		g := tg.Gear
+		if d.Internal && g.Internal {
+			return fmt.Errorf("gears %d and %d are both internal", tg.From+1,
+				i+1)
+		}
+		if math.Abs(d.GetModule()-g.GetModule()) > 1e-9 ||
+			math.Abs(d.A-g.A) > 1e-9 {
+			return fmt.Errorf("gears %d and %d have different modules or "+
+				"pressure angles and cannot mesh", tg.From+1, i+1)
+		}
+		if err := t.pair(i).CheckHelix(); err != nil {
+			return fmt.Errorf("gears %d and %d: %v", tg.From+1, i+1, err)
+		}
+	}
+	return nil
+}
+
+// Return the pair of gear i in mesh with the gear that drives it. An
+// internal gear is always the second of the pair.
+func (t Train) pair(i int) Pair {
+	d, g := t.Gears[t.Gears[i].From].Gear, t.Gears[i].Gear
+	if d.Internal {
+		d, g = g, d
+	}
+	return Pair{G1: d, G2: g, C: WorkingCentreDistance(d, g)}
+}
+
+// Return each pair of gears in mesh, in the order of the train.
+func (t Train) Pairs() []Pair {
+	var retval []Pair
+	for i, tg := range t.Gears {
+		if i > 0 && !tg.Compound {
+			retval = append(retval, t.pair(i))
+		}
+	}
+	return retval
+}
+
+// Return where each gear of the train sits and the rotation of each so the
+// teeth mesh at every contact. rotfrac is the percentage of one tooth that
+// the first gear is turned through, as for Pair.GetRotations.
+func (t Train) Layout(rotfrac float64) ([]Placement, error) {
+	if err := t.Check(); err != nil {
+		return nil, err
+	}
+	retval := make([]Placement, len(t.Gears))
+	first := t.Gears[0].Gear
+	retval[0] = Placement{Rot: (rotfrac / 100) * (360 / float64(first.N)),
+		Speed: 1}
+	for i, tg := range t.Gears[1:] {
+		i++
+		p := retval[tg.From]
+		if tg.Compound {
+			retval[i] = p
+			continue
+		}
+		d, g := t.Gears[tg.From].Gear, tg.Gear
+		c := WorkingCentreDistance(d, g)
+		ang := tg.Angle * DegToRad
+		centre := Point{p.Centre.X + c*math.Cos(ang),
+			p.Centre.Y + c*math.Sin(ang)}
+		// The direction of the contact from the centre of each gear. It is
+		// on the far side of a pinion from the centre of an internal gear.
+		dc, gc := tg.Angle, tg.Angle+180
+		if g.Internal {
+			dc = tg.Angle + 180
+		} else if d.Internal {
+			gc = tg.Angle
+		}
+		// How far through a pitch each gear is at the contact, 0 at the
+		// middle of a tooth. A tooth of one must meet a space of the other,
+		// and the count runs the same way on both unless the two turn in
+		// opposite directions.
+		fd := toothFraction(d, dc, p.Rot)
+		fg := 0.5 - fd
+		speed := -p.Speed * float64(d.N) / float64(g.N)
+		if d.Internal || g.Internal {
+			fg = fd + 0.5
+			speed = -speed
+		}
+		retval[i] = Placement{Centre: centre, Rot: gc - (fg+toothStart(g))*
+			360/float64(g.N), Speed: speed}
+	}
+	return retval, nil
+}
+
+// Return the fraction of a pitch that the middle of a tooth of gear g,
+// turned through rot degrees, lies before the direction ang in degrees.
+func toothFraction(g Gear, ang, rot float64) float64 {
+	return (ang-rot)*float64(g.N)/360 - toothStart(g)
+}
+
+// Return where the first tooth of gear g lies, as a fraction of a pitch
+// from the x axis. An internal gear has a space there instead.
+func toothStart(g Gear) float64 {
+	if g.Internal {
+		return 0.5
+	}
+	return 0
+}
+
+// Calculate and return the overall ratio of the train, the turns of the
+// first gear for each turn of the last. It is negative if the two turn in
+// opposite directions.
+func (t Train) GetRatio() float64 {
+	speed := 1.0
+	speeds := make([]float64, len(t.Gears))
+	for i, tg := range t.Gears {
+		if i > 0 {
+			speed = speeds[tg.From]
+			if !tg.Compound {
+				speed *= -t.Gears[tg.From].Gear.sign() * tg.Gear.sign() *
+					float64(t.Gears[tg.From].Gear.N) / float64(tg.Gear.N)
+			}
+		}
+		speeds[i] = speed
+	}
+	return 1 / speeds[len(speeds)-1]
+}
+
+// Spit out a load of text that describes this train.
+func (t Train) String() string {
+	var retval string
+	for i, tg := range t.Gears {
+		kind := "external"
+		if tg.Gear.Internal {
+			kind = "internal"
+		}
+		retval += fmt.Sprintf("Gear %-3d                %d teeth, %s", i+1,
+			tg.Gear.N, kind)
+		switch {
+		case i == 0:
+			retval += ", driver\n"
+		case tg.Compound:
+			retval += fmt.Sprintf(", on the shaft of gear %d\n", tg.From+1)
+		default:
+			retval += fmt.Sprintf(", driven by gear %d at %.1f degrees\n",
+				tg.From+1, tg.Angle)
+		}
+	}
+	retval += fmt.Sprintf("Overall Ratio:           %.4f\n", t.GetRatio())
+	return retval
+}
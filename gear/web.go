@@ -0,0 +1,263 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gear
+
+import (
+	"fmt"
+	"math"
+)
+
+// The ways the web between the hub and the rim of a gear can be lightened.
+type WebKind int
+
+const (
+	SolidWeb WebKind = iota // No lightening
+	HoleWeb                 // Round holes on a pitch circle
+	SpokeWeb                // Straight spokes with the web cut away between
+)
+
+var webNames = []string{"solid", "holes", "spokes"}
+
+// Return the name of the kind of web, as given to ParseWebKind.
+func (k WebKind) String() string {
+	if k < 0 || int(k) >= len(webNames) {
+		return fmt.Sprintf("WebKind(%d)", int(k))
+	}
+	return webNames[k]
+}
+
+// Return the kind of web with the given name. An empty name is a solid web.
+func ParseWebKind(s string) (WebKind, error) {
+	if s == "" {
+		return SolidWeb, nil
+	}
+	for i, n := range webNames {
+		if n == s {
+			return WebKind(i), nil
+		}
+	}
+	return SolidWeb, fmt.Errorf("unknown web type %q", s)
+}
+
+// Structure to hold the lightening of the web of a gear. The first hole,
+// or the first spoke, is on the positive x axis.
+type Web struct {
+	Kind WebKind
+	N    int     // number of holes or spokes
+	Size float64 // diameter of the holes or width of the spokes, 0 for a default
+	Rim  float64 // width of the rim below the root, 0 for 2.5 modules
+	Hub  float64 // hub diameter, 0 for a default
+	PCD  float64 // pitch circle of the holes, 0 for midway between hub and rim
+}
+
+// Calculate and return the inside diameter of the rim that carries the
+// teeth of g.
+func (g Gear) GetWebRimDia() float64 {
+	rim := g.Web.Rim
+	if rim <= 0 {
+		rim = 2.5 * g.GetModule()
+	}
+	return g.GetRootCircleDia() - 2*rim
+}
+
+// Calculate and return the diameter of the hub around the bore of g. Unless
+// it is given, the hub is half as big again as the bore, and no less than a
+// quarter of the root diameter.
+func (g Gear) GetHubDia() float64 {
+	if g.Web.Hub > 0 {
+		return g.Web.Hub
+	}
+	return math.Max(3*g.Bore.GetMaxRadius(), g.GetRootCircleDia()/4)
+}
+
+// Calculate and return the pitch circle diameter of the lightening holes.
+func (g Gear) GetHolePCD() float64 {
+	if g.Web.PCD > 0 {
+		return g.Web.PCD
+	}
+	return (g.GetHubDia() + g.GetWebRimDia()) / 2
+}
+
+// Calculate and return the diameter of the lightening holes, or the width
+// of the spokes. Unless it is given, holes take up most of the space on
+// their pitch circle, and spokes are a fifth of the hub diameter.
+func (g Gear) GetWebSize() float64 {
+	if g.Web.Size > 0 {
+		return g.Web.Size
+	}
+	switch g.Web.Kind {
+	case HoleWeb:
+		pcd := g.GetHolePCD()
+		d := math.Min(pcd-g.GetHubDia(), g.GetWebRimDia()-pcd)
+		if g.Web.N > 1 {
+			d = math.Min(d, pcd*math.Sin(math.Pi/float64(g.Web.N)))
+		}
+		return 0.8 * d
+	case SpokeWeb:
+		return g.GetHubDia() / 5
+	}
+	return 0
+}
+
+// Check that the web of g can be made, leaving material between the holes
+// and round the hub and rim.
+func (g Gear) CheckWeb() error {
+	w := g.Web
+	if w.Kind == SolidWeb {
+		return nil
+	}
+	if g.Internal {
+		return fmt.Errorf("an internal gear has no web to lighten")
+	}
+	if w.N < 1 {
+		return fmt.Errorf("a web of %s needs at least one", w.Kind)
+	}
+	for _, f := range []struct {
+		name string
+		v    float64
+	}{{"size", w.Size}, {"rim", w.Rim}, {"hub", w.Hub},
+		{"pitch circle", w.PCD}} {
+		if !(f.v >= 0) || math.IsInf(f.v, 0) {
+			return fmt.Errorf("the web %s cannot be %g", f.name, f.v)
+		}
+	}
+	if w.Kind == SpokeWeb && w.PCD != 0 {
+		return fmt.Errorf("a web of spokes has no pitch circle")
+	}
+	rim, hub := g.GetWebRimDia(), g.GetHubDia()
+	if hub <= 2*g.Bore.GetMaxRadius() {
+		return fmt.Errorf("the hub diameter %.3f mm does not clear the bore",
+			hub)
+	}
+	if rim <= hub {
+		return fmt.Errorf("the hub diameter %.3f mm is past the inside of the "+
+			"rim at %.3f mm", hub, rim)
+	}
+	size, n := g.GetWebSize(), float64(w.N)
+	switch w.Kind {
+	case HoleWeb:
+		pcd := g.GetHolePCD()
+		if pcd-size <= hub || pcd+size >= rim {
+			return fmt.Errorf("holes of %.3f mm on a %.3f mm pitch circle "+
+				"do not fit between the hub and the rim", size, pcd)
+		}
+		if w.N > 1 && pcd*math.Sin(math.Pi/n) <= size {
+			return fmt.Errorf("%d holes of %.3f mm overlap on a %.3f mm "+
+				"pitch circle", w.N, size, pcd)
+		}
+	case SpokeWeb:
+		if w.N < 2 || size/2 >= hub/2*math.Sin(math.Pi/n) {
+			return fmt.Errorf("%d spokes %.3f mm wide leave no room between "+
+				"them at the hub", w.N, size)
+		}
+	}
+	return nil
+}
+
+// Return the outlines of the lightening holes, each running anticlockwise.
+func (g Gear) webOutlines() []Outline {
+	w := g.Web
+	if g.Internal || w.N < 1 {
+		return nil
+	}
+	var retval []Outline
+	step := 2 * math.Pi / float64(w.N)
+	size := g.GetWebSize()
+	switch w.Kind {
+	case HoleWeb:
+		hole := circleOutline(size/2, false).Translate(g.GetHolePCD()/2, 0)
+		for i := 0; i < w.N; i++ {
+			retval = append(retval, hole.Rotate(step*float64(i)))
+		}
+	case SpokeWeb:
+		// Each window runs out along the upper edge of one spoke, round the
+		// rim and back along the lower edge of the next to the hub.
+		R, r, h := g.GetWebRimDia()/2, g.GetHubDia()/2, size/2
+		pa := Point{math.Sqrt(r*r - h*h), h}
+		pb := Point{math.Sqrt(R*R - h*h), h}
+		pc := Point{pb.X, -h}.Rotate(step)
+		pd := Point{pa.X, -h}.Rotate(step)
+		window := Outline{
+			{Kind: Line, Points: []Point{pa, pb}},
+			{Kind: Arc, Points: []Point{pb, pc}, Radius: R},
+			{Kind: Line, Points: []Point{pc, pd}},
+			{Kind: Arc, Points: []Point{pd, pa}, Radius: r, Clockwise: true},
+		}
+		for i := 0; i < w.N; i++ {
+			retval = append(retval, window.Rotate(step*float64(i)))
+		}
+	}
+	return retval
+}
+
+// Return the outlines of all the holes through the gear, the bore and any
+// lightening, each running anticlockwise about its own middle.
+func (g Gear) Holes() []Outline {
+	var retval []Outline
+	if g.Internal {
+		return nil
+	}
+	if b := g.Bore.Outline(); b != nil {
+		retval = append(retval, b)
+	}
+	return append(retval, g.webOutlines()...)
+}
+
+// Return the area of the outline and its second moment about the origin,
+// the integral of r squared over the area. Both are negative for an outline
+// that runs clockwise.
+func (o Outline) moments() (float64, float64) {
+	// Follow arcs closely, as the chords cut a little off each one.
+	pts := o.Polygon(1e-5)
+	var a, j float64
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		c := p.X*q.Y - q.X*p.Y
+		a += c
+		j += c * (p.X*p.X + p.X*q.X + q.X*q.X + p.Y*p.Y + p.Y*q.Y + q.Y*q.Y)
+	}
+	return a / 2, j / 12
+}
+
+// Calculate and return the area of the face of the gear in mm², with all
+// the holes taken away.
+func (g Gear) GetArea() float64 {
+	var area float64
+	for _, o := range g.contours() {
+		a, _ := o.moments()
+		area += a
+	}
+	return area
+}
+
+// Calculate and return the mass in kg of a gear face mm thick, made of a
+// material of the given density in kg/m³.
+func (g Gear) GetMass(face, density float64) float64 {
+	return g.GetArea() * face * density * 1e-9
+}
+
+// Calculate and return the moment of inertia about the axis of the gear in
+// kg mm², for a gear face mm thick made of a material of the given density
+// in kg/m³.
+func (g Gear) GetInertia(face, density float64) float64 {
+	var j float64
+	for _, o := range g.contours() {
+		_, m := o.moments()
+		j += m
+	}
+	return j * face * density * 1e-9
+}
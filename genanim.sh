@@ -1,6 +1,6 @@
 #!/bin/bash
-#     genanim.sh -- Simple utility to generate a sequence of images for
-#     animation
+#     genanim.sh -- Simple utility to generate an animation of a pair of
+#     gears
 #     Copyright (C) 2015  Philip Stubbs
 #
 #     This program is free software: you can redistribute it and/or modify
@@ -16,22 +16,7 @@
 #     You should have received a copy of the GNU General Public License
 #     along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
-function gencon {
-  f=`printf "anim_%02d" $1`
-  echo "Working on frame $1"
-  GearGen -o $f -r $1 -n1 17 -n2 23
-  inkscape --without-gui --file $f.svg --export-dpi=150 --export-png=$f.png &>/dev/null
-  convert $f.png -flatten -resize 50% $f.png
-  rm $f.svg
-}
-
-for ((i=0; i<100; i++))
-do
-  gencon $i &
-done
-echo "Waiting for jobs to complete."
-wait
-echo "Now make animation..."
-convert -delay 5 -loop 0 anim*.png animation.gif
-rm anim_??.png
+# GearGen animates the drawing itself, so all that is needed is a single
+# SVG that can be opened in a web browser.
+GearGen -o animation -n1 17 -n2 23 -anim 10
 echo "All done."
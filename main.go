@@ -19,56 +19,884 @@
 package main
 
 import (
+	"errors"
 	"flag"
-	"strconv"
+	"fmt"
+	"github.com/stuphi/GearGen/dxf"
+	"github.com/stuphi/GearGen/gcode"
 	"github.com/stuphi/GearGen/gear"
 	"github.com/stuphi/GearGen/plot"
+	"github.com/stuphi/GearGen/scad"
+	"github.com/stuphi/GearGen/stl"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
 )
 
+// Report whether the named flag was set on the command line.
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
+
+// Open the output file fname with extension ext added, or return stdout if
+// no file name was given.
+func create(fname, ext string) (*os.File, error) {
+	if fname == "" {
+		return os.Stdout, nil
+	}
+	return os.Create(fname + ext)
+}
+
+// Write a file fname with extension ext added, or stdout if no file name was
+// given, with the contents written by fn.
+func write(fname, ext string, fn func(io.Writer) error) error {
+	f, err := create(fname, ext)
+	if err != nil {
+		return err
+	}
+	err = fn(f)
+	if f != os.Stdout {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Write the drawing in the format given, with svg drawn by plotSVG and dxf
+// written by plotDXF.
+func output(format string, fname string, plotSVG func(io.Writer) error,
+	plotDXF func(io.Writer) error) error {
+	switch format {
+	case "svg":
+		return write(fname, ".svg", plotSVG)
+	case "dxf":
+		return write(fname, ".dxf", plotDXF)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// Report whether format is one of formats.
+func hasFormat(formats []string, format string) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Make a bore of diameter dia and the kind named, and check that it fits
+// gear g.
+func bore(g gear.Gear, dia float64, kind string, flat float64) (gear.Bore,
+	error) {
+	k, err := gear.ParseBoreKind(kind)
+	if err != nil {
+		return gear.Bore{}, err
+	}
+	b := gear.Bore{Dia: dia, Kind: k, Flat: flat}
+	return b, b.Check(g.GetRootCircleDia())
+}
+
+// Make a web of the kind named with n holes or spokes of the size given,
+// inside a rim of the width given, around a hub of diameter hub and with
+// any holes on a pitch circle of diameter pcd, and check that it fits gear
+// g. Any size of 0 is a default.
+func web(g gear.Gear, kind string, n int, size, rim, hub,
+	pcd float64) (gear.Web, error) {
+	k, err := gear.ParseWebKind(kind)
+	if err != nil {
+		return gear.Web{}, err
+	}
+	g.Web = gear.Web{Kind: k, N: n, Size: size, Rim: rim, Hub: hub, PCD: pcd}
+	return g.Web, g.CheckWeb()
+}
+
+// Validate gear g and print each problem found, naming the gear as name.
+// Report whether the gear is sound.
+func valid(name string, g gear.Gear) bool {
+	err := g.Validate()
+	if err == nil {
+		return true
+	}
+	var ve *gear.ValidationError
+	if !errors.As(err, &ve) {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", name, err)
+		return false
+	}
+	for _, e := range ve.Errors {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", name, e)
+	}
+	return false
+}
+
+// Check the number of teeth n of the gear named, and print any problem.
+// Report whether it is sound.
+func validTeeth(name string, n int) bool {
+	if err := gear.CheckTeeth(n); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", name, err)
+		return false
+	}
+	return true
+}
+
+// Return a report of the mass and inertia of gear g, made face mm thick of
+// material with the given density.
+func mass(g gear.Gear, face, density float64) string {
+	return fmt.Sprintf("Mass:                    %.4f kg\n"+
+		"Inertia:                 %.3f kg mm²\n", g.GetMass(face, density),
+		g.GetInertia(face, density))
+}
+
+// Lay out the contours of each part side by side and write them in the
+// format given, ready to cut.
+func cut(format string, fname string, opts plot.Options,
+	parts ...[]gear.Outline) error {
+	cs := gear.Layout(5, parts...)
+	return output(format, fname, func(w io.Writer) error {
+		return plot.Cut(w, cs, opts)
+	}, func(w io.Writer) error {
+		return dxf.Cut(w, cs)
+	})
+}
+
+// Lay out the contours of each part side by side and write a G-code program
+// to mill them out.
+func mill(fname string, opts gcode.Options, parts ...[]gear.Outline) error {
+	cs := gear.Layout(5, parts...)
+	return write(fname, ".nc", func(w io.Writer) error {
+		return gcode.Cut(w, cs, opts)
+	})
+}
+
+// Return a warning if a cutter of diameter dia is too wide for the root gap
+// of the part named.
+func rootGap(name string, gap, dia float64) []string {
+	if dia > gap {
+		return []string{fmt.Sprintf("%s: a %.3f mm cutter is wider than the "+
+			"%.3f mm root gap and cannot cut the tooth spaces", name, dia, gap)}
+	}
+	return nil
+}
+
+// Extrude the gears, and any racks face mm thick, and write the solids side
+// by side to an STL file ready to print.
+func solid(fname string, ascii bool, face float64, gs []gear.Gear,
+	rs ...gear.Rack) error {
+	var ms []stl.Mesh
+	for _, g := range gs {
+		m, err := stl.Gear(g)
+		if err != nil {
+			return err
+		}
+		ms = append(ms, m)
+	}
+	for _, r := range rs {
+		m, err := stl.Rack(r, face)
+		if err != nil {
+			return err
+		}
+		ms = append(ms, m)
+	}
+	m := stl.Layout(5, ms...)
+	return write(fname, ".stl", func(w io.Writer) error {
+		if ascii {
+			name := fname
+			if name == "" {
+				name = "GearGen"
+			}
+			return m.WriteASCII(w, name)
+		}
+		return m.WriteBinary(w)
+	})
+}
+
 func main() {
 
 	var Centres float64 // Distance between Centres
+	var Module float64  // Metric module, zero if centres are given instead
 	var Ratio float64   // Required Ratio
 	var DriveTeeth int  // Number of teeth on drive gear
 	var DrivenTeeth int // Number of teeth on drive gear
 	var PressureAngle float64
 	var Backlash float64
-	var Rotation int    // Percent of rotation
-	var FileName string // File name for output.
+	var Shift1 float64    // Profile shift coefficient of drive gear
+	var Shift2 float64    // Profile shift coefficient of driven gear
+	var TipRadius float64 // Rack cutter tip radius coefficient
+	var Rotation int      // Percent of rotation
+	var FileName string   // File name for output.
+	var Formats []string  // Output file formats
+	var Report bool       // Print a report of the design
+	var Annotate bool     // Annotate drawing with contact ratio
+	var Internal bool     // Second gear is an internal ring gear
+	var Rim float64       // Rim diameter of an internal gear
+	var RackTeeth int     // Number of teeth on the rack, zero for no rack
+	var RackLength float64
+	var Period float64 // Seconds per turn of the first gear when animated
+	var Precision int  // Decimal places in svg output
+	var Cut bool       // Write only the contours to cut
+	var Bore1, Bore2 float64
+	var BoreType1, BoreType2 string
+	var Flat1, Flat2 float64
+	var Web1, Web2 string
+	var WebN1, WebN2 int
+	var WebSize1, WebSize2 float64
+	var WebRim1, WebRim2 float64
+	var WebHub1, WebHub2 float64
+	var WebPCD1, WebPCD2 float64
+	var Face float64    // Face width of the gears
+	var Density float64 // Density of the material of the gears
+	var Helix float64   // Helix angle, zero for spur gears
+	var Hand gear.Hand  // Hand of the helix of the first gear
+	var ASCII bool      // Write STL as text
+	var Mill gcode.Options
+	var Kerf float64       // Width burnt away by the cutter
+	var Planets int        // Number of planets, zero for no planetary set
+	var Fixed gear.Member  // Member of the planetary set held still
+	var Search gear.Search // Limits of a search for tooth counts
+	var Styles = styles{}
+	var Design Config // Design read from a file
 
-	var pCentres = flag.Int("c", 100, "Distance between centres. (Whole mm only)")
+	var pConfig = flag.String("config", "", "Read the design from this JSON file. Flags given on the command line take precedence")
+	var pCentres = flag.Float64("c", 100, "Distance between centres (mm)")
+	var pModule = flag.Float64("m", 0, "Module (mm). If given, the centre distance is derived from it unless -c is also given")
 	var pDriveTeeth = flag.Int("n1", 7, "Number of teeth on the first gear")
 	var pDrivenTeeth = flag.Int("n2", 23, "Number of teeth on the second gear")
-	var pPressureAngle = flag.Int("p", 25, "Pressure angle")
+	var pPressureAngle = flag.Float64("p", 25, "Pressure angle (degrees)")
 	var pBacklash = flag.String("b", "0.5", "Backlash angle (degrees)")
-	var pFileName = flag.String("o", "", "Output file name, .svg will be appended. stdout if not given")
+	var pShift1 = flag.Float64("x1", 0, "Profile shift coefficient of the first gear")
+	var pShift2 = flag.Float64("x2", 0, "Profile shift coefficient of the second gear. With -m and -c, this is calculated so the pair meshes at the given centres")
+	var pTipRadius = flag.Float64("rf", 0.38, "Tip radius of the rack cutter, as a multiple of the module. Sets the root fillet")
+	var pFileName = flag.String("o", "", "Output file name, the extension for the format will be appended. stdout if not given")
+	var pFormat = flag.String("f", "svg", "Output format, svg, dxf, stl, scad or gcode, or several separated by commas, which needs -o. An stl file holds each part extruded to the face width, side by side. A scad file holds OpenSCAD modules for each part and the pair in mesh. A gcode file mills each part out of plate")
 	var pRotation = flag.Int("r", 0, "Rotation as percentage of one tooth")
+	var pReport = flag.Bool("t", false, "Print a text report of the design to stderr")
+	var pAnnotate = flag.Bool("a", false, "Annotate the drawing with the contact ratio")
+	var pInternal = flag.Bool("i", false, "Make the second gear an internal ring gear, with the first gear running inside it")
+	var pRim = flag.Float64("rim", 0, "Rim diameter of the internal gear (mm). 10 modules beyond the root if not given")
+	var pRackTeeth = flag.Int("rack", 0, "Number of teeth on a rack to mesh with the first gear in place of the second gear. With -c, the centres are the height of the gear above the pitch line of the rack")
+	var pRackLength = flag.Float64("rl", 0, "Overall length of the rack (mm). Just long enough for the teeth if not given")
+	var pPeriod = flag.Float64("anim", 0, "Animate the svg drawing, with the first gear turning once every this many seconds. 0 for a still drawing")
+	var pCut = flag.Bool("cut", false, "Write only the closed contours to cut each gear, laid out side by side")
+	var pBore1 = flag.Float64("bore1", 0, "Bore diameter of the first gear (mm), across the flats for a hex. No bore if not given")
+	var pBore2 = flag.Float64("bore2", 0, "Bore diameter of the second gear (mm)")
+	var pBoreType1 = flag.String("bt1", "round", "Bore type of the first gear: round, din6885 or ansi keyway, dflat or hex")
+	var pBoreType2 = flag.String("bt2", "round", "Bore type of the second gear")
+	var pFlat1 = flag.Float64("flat1", 0, "Distance across a D-flat bore in the first gear (mm). 0.9 of the bore if not given")
+	var pFlat2 = flag.Float64("flat2", 0, "Distance across a D-flat bore in the second gear (mm)")
+	var pWeb1 = flag.String("web1", "", "Lighten the web of the first gear with holes or spokes")
+	var pWeb2 = flag.String("web2", "", "Lighten the web of the second gear with holes or spokes")
+	var pWebN1 = flag.Int("wn1", 6, "Number of lightening holes or spokes in the first gear")
+	var pWebN2 = flag.Int("wn2", 6, "Number of lightening holes or spokes in the second gear")
+	var pWebSize1 = flag.Float64("ws1", 0, "Diameter of the lightening holes or width of the spokes in the first gear (mm). Sized to fit if not given")
+	var pWebSize2 = flag.Float64("ws2", 0, "Diameter of the lightening holes or width of the spokes in the second gear (mm)")
+	var pWebRim1 = flag.Float64("webrim1", 0, "Width of the rim left below the teeth of the first gear by its web (mm). 2.5 modules if not given")
+	var pWebRim2 = flag.Float64("webrim2", 0, "Width of the rim left below the teeth of the second gear by its web (mm)")
+	var pWebHub1 = flag.Float64("webhub1", 0, "Diameter of the hub left around the bore of the first gear by its web (mm). Sized from the bore if not given")
+	var pWebHub2 = flag.Float64("webhub2", 0, "Diameter of the hub left around the bore of the second gear by its web (mm)")
+	var pWebPCD1 = flag.Float64("webpcd1", 0, "Pitch circle diameter of the lightening holes in the first gear (mm). Midway between the hub and the rim if not given")
+	var pWebPCD2 = flag.Float64("webpcd2", 0, "Pitch circle diameter of the lightening holes in the second gear (mm)")
+	var pFace = flag.Float64("face", 10, "Face width of the gears (mm), used for the mass in the report and the thickness of stl and scad solids")
+	var pDensity = flag.Float64("density", 7850, "Density of the gear material (kg/m³), used for the mass in the report")
+	var pHelix = flag.Float64("helix", 0, "Helix angle (degrees) for helical gears. The module and pressure angle are then in the normal plane")
+	var pHand = flag.String("hand", "right", "Hand of the helix of the first gear, right or left. The second gear is made to mesh")
+	var pASCII = flag.Bool("ascii", false, "Write stl files as ASCII text rather than binary")
+	var pTool = flag.Float64("tool", 3.175, "Diameter of the cutter for gcode output (mm)")
+	var pDepth = flag.Float64("depth", 0, "Depth to mill for gcode output (mm). The face width if not given")
+	var pStep = flag.Float64("step", 1, "Greatest depth of each pass for gcode output (mm). 0 to cut in one pass")
+	var pFeed = flag.Float64("feed", 600, "Cutting feed rate for gcode output (mm/min)")
+	var pPlunge = flag.Float64("plunge", 0, "Plunge feed rate for gcode output (mm/min). Half the cutting feed if not given")
+	var pRPM = flag.Float64("rpm", 18000, "Spindle speed for gcode output (rpm). 0 to leave the spindle alone")
+	var pSafe = flag.Float64("safe", 5, "Height to move at between cuts for gcode output (mm)")
+	var pTabs = flag.Int("tabs", 0, "Number of tabs holding each part for gcode output")
+	var pTabW = flag.Float64("tabw", 3, "Width of each tab for gcode output (mm)")
+	var pTabH = flag.Float64("tabh", 1, "Height of each tab for gcode output (mm)")
+	var pLead = flag.Float64("lead", 2, "Radius of the arcs into and out of each cut for gcode output (mm). 0 to go straight in")
+	var pKerf = flag.Float64("kerf", 0, "Width of the cut made by a laser or other cutter (mm). The parts are grown by half of it all round to make up for it. Applies to -cut, gcode, stl and scad output, not to drawings of the parts in mesh")
+	var pPlanets = flag.Int("planets", 0, "Number of planets in a planetary set, with the first gear as the sun and the second as the ring. The planets are sized to fit between. Needs -m")
+	var pFixed = flag.String("fixed", "ring", "Member of a planetary set held still: ring, carrier or sun. The sun drives unless it is held, when the ring drives")
+	var pSearch = flag.Float64("ratio", 0, "Search for the tooth counts of a train with this ratio, turns of the first gear for each turn of the last, and print the best instead of drawing, to stdout or a .txt or .json file. Uses -p and -rf")
+	var pTolerance = flag.Float64("tol", 0.01, "Greatest error of the ratio in a search, as a fraction of it")
+	var pModules = flag.String("modules", "", "Modules to try in a search, separated by commas. -m if not given")
+	var pMinTeeth = flag.Int("nmin", 12, "Fewest teeth on any gear in a search")
+	var pMaxTeeth = flag.Int("nmax", 100, "Most teeth on any gear in a search")
+	var pMinCentres = flag.Float64("cmin", 0, "Least centre distance of each stage in a search (mm)")
+	var pMaxCentres = flag.Float64("cmax", 0, "Greatest centre distance of each stage in a search (mm). 0 for no limit")
+	var pMinContact = flag.Float64("crmin", gear.DefaultMinContactRatio, "Least contact ratio of each stage in a search")
+	var pStages = flag.Int("stages", 2, "Most stages in a search, up to 3")
+	var pResults = flag.Int("results", 20, "Number of results to print from a search. 0 for all")
+	var pJSON = flag.Bool("json", false, "Print the results of a search as JSON rather than a table")
+	var pPrecision = flag.Int("prec", plot.DefaultPrecision, "Number of decimal places for dimensions in svg output, 0 for whole units")
+	flag.Var(Styles, "style", "Line style for svg drawings as name=css, where the name is one of solid, dash, thin, grid or anott. May be given more than once")
 	flag.Parse()
-	Centres = float64(*pCentres)
+	var err error
+	if *pConfig != "" {
+		if Design, err = loadConfig(*pConfig); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	}
+	Centres = *pCentres
+	Module = *pModule
 	DriveTeeth = *pDriveTeeth
 	DrivenTeeth = *pDrivenTeeth
-	PressureAngle = float64(*pPressureAngle)
-	var err error
+	PressureAngle = *pPressureAngle
 	Backlash, err = strconv.ParseFloat(*pBacklash, 64)
 	if err != nil {
 		Backlash = 0.0
 	}
+	Shift1 = *pShift1
+	Shift2 = *pShift2
+	TipRadius = *pTipRadius
 	Rotation = *pRotation
 	FileName = *pFileName
+	Formats = strings.Split(*pFormat, ",")
+	Report = *pReport
+	Annotate = *pAnnotate
+	Internal = *pInternal
+	Rim = *pRim
+	RackTeeth = *pRackTeeth
+	RackLength = *pRackLength
+	Period = *pPeriod
+	Precision = *pPrecision
+	Cut = *pCut
+	Bore1, Bore2 = *pBore1, *pBore2
+	BoreType1, BoreType2 = *pBoreType1, *pBoreType2
+	Flat1, Flat2 = *pFlat1, *pFlat2
+	Web1, Web2 = *pWeb1, *pWeb2
+	WebN1, WebN2 = *pWebN1, *pWebN2
+	WebSize1, WebSize2 = *pWebSize1, *pWebSize2
+	WebRim1, WebRim2 = *pWebRim1, *pWebRim2
+	WebHub1, WebHub2 = *pWebHub1, *pWebHub2
+	WebPCD1, WebPCD2 = *pWebPCD1, *pWebPCD2
+	Face = *pFace
+	Density = *pDensity
+	Helix = *pHelix
+	ASCII = *pASCII
+	Kerf = *pKerf
+	Planets = *pPlanets
+	Mill = gcode.Options{ToolDia: *pTool, Depth: *pDepth, StepDown: *pStep,
+		Feed: *pFeed, PlungeFeed: *pPlunge, Speed: *pRPM, SafeZ: *pSafe,
+		Tabs: *pTabs, TabWidth: *pTabW, TabHeight: *pTabH, LeadIn: *pLead}
+	if Mill.Depth <= 0 {
+		Mill.Depth = Face
+	}
+	Hand, err = gear.ParseHand(*pHand)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	Fixed, err = gear.ParseMember(*pFixed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if Precision < 0 {
+		fmt.Fprintf(os.Stderr, "Error: the number of decimal places cannot "+
+			"be negative, not %d\n", Precision)
+		os.Exit(1)
+	}
+	if Precision == 0 {
+		Precision = plot.WholeUnits
+	}
+	// A module of 0 stands for none given, so any value given must be
+	// greater than 0, as must any centre distance.
+	if flagGiven("m") && !(Module > 0 && !math.IsInf(Module, 0)) {
+		fmt.Fprintf(os.Stderr, "Error: the module must be greater than 0, "+
+			"not %g\n", Module)
+		os.Exit(1)
+	}
+	if flagGiven("c") && !(Centres > 0 && !math.IsInf(Centres, 0)) {
+		fmt.Fprintf(os.Stderr, "Error: the centre distance must be greater "+
+			"than 0, not %g\n", Centres)
+		os.Exit(1)
+	}
+	if Planets > 0 && (len(Design.Train) > 0 || RackTeeth > 0) {
+		fmt.Fprintln(os.Stderr, "Error: a planetary set cannot have a train "+
+			"or a rack")
+		os.Exit(1)
+	}
+	if len(Formats) > 1 && FileName == "" {
+		fmt.Fprintln(os.Stderr, "Error: more than one format needs a file "+
+			"name, use -o")
+		os.Exit(1)
+	}
+	for _, f := range Formats {
+		if Kerf != 0 && !Cut && (f == "svg" || f == "dxf") {
+			fmt.Fprintln(os.Stderr, "Error: kerf only applies to parts "+
+				"written to cut, use -cut")
+			os.Exit(1)
+		}
+		if Period > 0 && f != "svg" {
+			fmt.Fprintln(os.Stderr, "Error: only svg drawings can be animated")
+			os.Exit(1)
+		}
+	}
+	if Cut && Period > 0 {
+		fmt.Fprintln(os.Stderr, "Error: contours for cutting cannot be animated")
+		os.Exit(1)
+	}
+	if *pSearch != 0 {
+		Search = gear.Search{Ratio: *pSearch, Tolerance: *pTolerance,
+			Modules: []float64{Module}, MinTeeth: *pMinTeeth,
+			MaxTeeth: *pMaxTeeth, MinCentres: *pMinCentres,
+			MaxCentres: *pMaxCentres, MinContactRatio: *pMinContact,
+			Stages: *pStages, A: PressureAngle, Rf: TipRadius,
+			Limit: *pResults}
+		if *pModules != "" {
+			Search.Modules, err = parseModules(*pModules)
+		} else if Module <= 0 {
+			err = fmt.Errorf("a search needs the module, use -m or -modules")
+		}
+		var sols []gear.Solution
+		if err == nil {
+			sols, err = Search.Run()
+		}
+		if err == nil {
+			ext := ".txt"
+			if *pJSON {
+				ext = ".json"
+			}
+			err = write(FileName, ext, func(w io.Writer) error {
+				return writeSolutions(w, sols, *pJSON)
+			})
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	SVGOptions := plot.Options{Annotate: Annotate, Period: Period,
+		Precision: Precision, Styles: Styles}
+
+	// Check the teeth before anything is worked out from them, so that a
+	// gear with too few is reported once, not again for the size it gives.
+	teethOK := true
+	switch {
+	case len(Design.Train) > 0:
+		// Each gear of a train is checked as it is made.
+	case Planets > 0:
+		teethOK = validTeeth("sun", DriveTeeth)
+		teethOK = validTeeth("ring", DrivenTeeth) && teethOK
+	case RackTeeth > 0:
+		teethOK = validTeeth("pinion", DriveTeeth)
+	default:
+		teethOK = validTeeth("first gear", DriveTeeth)
+		teethOK = validTeeth("second gear", DrivenTeeth) && teethOK
+	}
+	if !teethOK {
+		os.Exit(1)
+	}
+
+	if len(Design.Train) > 0 {
+		if Module <= 0 {
+			fmt.Fprintln(os.Stderr, "Error: a train needs the module, use -m")
+			os.Exit(1)
+		}
+		Train, err := Design.train(func(n int) gear.Gear {
+			g := gear.FromNormal(Module, n, PressureAngle, Backlash, Helix)
+			g.Rf = TipRadius
+			g.Rim = Rim
+			g.Face = Face
+			g.Kerf = Kerf
+			return g
+		}, Hand)
+		ok := true
+		for i, tg := range Train.Gears {
+			ok = valid(fmt.Sprintf("gear %d", i+1), tg.Gear) && ok
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		if Report {
+			fmt.Fprintf(os.Stderr, "Train\n%s", Train)
+			for i, p := range Train.Pairs() {
+				fmt.Fprintf(os.Stderr, "\nMesh %d\n%s", i+1, p)
+			}
+		}
+		var warnings []string
+		for i, p := range Train.Pairs() {
+			check1, check2 := gear.CheckInterference(p.G1, p.G2)
+			warnings = append(warnings, check1.Warnings(fmt.Sprintf(
+				"Mesh %d pinion", i+1))...)
+			warnings = append(warnings, check2.Warnings(fmt.Sprintf(
+				"Mesh %d gear", i+1))...)
+		}
+		var gears []gear.Gear
+		var parts [][]gear.Outline
+		for i, tg := range Train.Gears {
+			if hasFormat(Formats, "gcode") {
+				warnings = append(warnings, rootGap(fmt.Sprintf("Gear %d", i+1),
+					tg.Gear.GetRootGap(), Mill.ToolDia)...)
+			}
+			gears = append(gears, tg.Gear)
+			parts = append(parts, tg.Gear.Contours())
+		}
+		for _, w := range warnings {
+			fmt.Fprintln(os.Stderr, "Warning:", w)
+		}
+		for _, Format := range Formats {
+			if Format == "stl" {
+				err = solid(FileName, ASCII, Face, gears)
+			} else if Format == "scad" {
+				err = fmt.Errorf("scad output of a train is not supported")
+			} else if Format == "gcode" {
+				err = mill(FileName, Mill, parts...)
+			} else if Cut {
+				err = cut(Format, FileName, SVGOptions, parts...)
+			} else {
+				err = output(Format, FileName, func(w io.Writer) error {
+					return plot.PlotTrain(w, Train, Rotation, SVGOptions)
+				}, func(w io.Writer) error {
+					return dxf.PlotTrain(w, Train, Rotation)
+				})
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
+	if Planets > 0 {
+		if Module <= 0 {
+			fmt.Fprintln(os.Stderr, "Error: a planetary set needs the module, "+
+				"use -m")
+			os.Exit(1)
+		}
+		Sun := gear.FromNormal(Module, DriveTeeth, PressureAngle, Backlash,
+			Helix)
+		Ring := gear.FromNormal(Module, DrivenTeeth, PressureAngle, Backlash,
+			Helix)
+		Sun.X, Ring.X = Shift1, Shift2
+		Sun.Rf, Ring.Rf = TipRadius, TipRadius
+		Sun.Face, Ring.Face = Face, Face
+		Sun.Kerf, Ring.Kerf = Kerf, Kerf
+		Sun.Hand = Hand
+		Ring.Rim = Rim
+		Set := gear.NewPlanetary(Sun, Ring, Planets)
+		ok := valid("sun", Set.Sun)
+		ok = valid("planet", Set.Planet) && ok
+		ok = valid("ring", Set.Ring) && ok
+		if !ok {
+			os.Exit(1)
+		}
+		Set.Sun.Bore, err = bore(Set.Sun, Bore1, BoreType1, Flat1)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: sun bore:", err)
+			os.Exit(1)
+		}
+		Set.Sun.Web, err = web(Set.Sun, Web1, WebN1, WebSize1, WebRim1,
+			WebHub1, WebPCD1)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: sun web:", err)
+			os.Exit(1)
+		}
+		if err := Set.Check(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		if Report {
+			fmt.Fprintf(os.Stderr, "Planetary\n%s\nSun\n%s%s\nPlanet\n%s%s"+
+				"\nRing\n%s%s\nSun and Planet\n%s\nPlanet and Ring\n%s",
+				Set, Set.Sun, mass(Set.Sun, Face, Density), Set.Planet,
+				mass(Set.Planet, Face, Density), Set.Ring,
+				mass(Set.Ring, Face, Density), Set.SunPair(), Set.RingPair())
+		}
+		check1, check2 := gear.CheckInterference(Set.Sun, Set.Planet)
+		warnings := append(check1.Warnings("Sun"), check2.Warnings("Planet")...)
+		check1, check2 = gear.CheckInterference(Set.Planet, Set.Ring)
+		warnings = append(warnings, check1.Warnings("Planet")...)
+		warnings = append(warnings, check2.Warnings("Ring")...)
+		gears := []gear.Gear{Set.Sun}
+		for i := 0; i < Planets; i++ {
+			gears = append(gears, Set.Planet)
+		}
+		gears = append(gears, Set.Ring)
+		var parts [][]gear.Outline
+		for _, g := range gears {
+			parts = append(parts, g.Contours())
+		}
+		if hasFormat(Formats, "gcode") {
+			warnings = append(warnings, rootGap("Sun", Set.Sun.GetRootGap(),
+				Mill.ToolDia)...)
+			warnings = append(warnings, rootGap("Planet",
+				Set.Planet.GetRootGap(), Mill.ToolDia)...)
+			warnings = append(warnings, rootGap("Ring", Set.Ring.GetRootGap(),
+				Mill.ToolDia)...)
+		}
+		for _, w := range warnings {
+			fmt.Fprintln(os.Stderr, "Warning:", w)
+		}
+		for _, Format := range Formats {
+			if Format == "stl" {
+				err = solid(FileName, ASCII, Face, gears)
+			} else if Format == "scad" {
+				err = fmt.Errorf("scad output of a planetary set is not " +
+					"supported")
+			} else if Format == "gcode" {
+				err = mill(FileName, Mill, parts...)
+			} else if Cut {
+				err = cut(Format, FileName, SVGOptions, parts...)
+			} else {
+				err = output(Format, FileName, func(w io.Writer) error {
+					return plot.PlotPlanetary(w, Set, Fixed, Rotation,
+						SVGOptions)
+				}, func(w io.Writer) error {
+					return dxf.PlotPlanetary(w, Set, Fixed, Rotation)
+				})
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
+	if RackTeeth > 0 {
+		if Helix != 0 {
+			fmt.Fprintln(os.Stderr, "Error: helical racks are not supported")
+			os.Exit(1)
+		}
+		// The rack takes the place of the second gear.
+		var Pinion gear.Gear
+		if Module > 0 {
+			Pinion = gear.FromModule(Module, DriveTeeth, PressureAngle, Backlash)
+		} else {
+			// The centre of the pinion sits its pitch radius plus any
+			// profile shift above the rack.
+			Pinion.Pd = Centres / (0.5 + Shift1/float64(DriveTeeth))
+			Pinion.N = DriveTeeth
+			Pinion.A = PressureAngle
+			Pinion.B = Backlash
+		}
+		Pinion.X = Shift1
+		Pinion.Rf = TipRadius
+		Pinion.Face = Face
+		Pinion.Kerf = Kerf
+		if !valid("pinion", Pinion) {
+			os.Exit(1)
+		}
+		Pinion.Bore, err = bore(Pinion, Bore1, BoreType1, Flat1)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: pinion bore:", err)
+			os.Exit(1)
+		}
+		Pinion.Web, err = web(Pinion, Web1, WebN1, WebSize1, WebRim1,
+			WebHub1, WebPCD1)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: pinion web:", err)
+			os.Exit(1)
+		}
+		Rack := gear.RackFor(Pinion, RackTeeth)
+		Rack.Length = RackLength
+		RackPair := gear.RackPair{G: Pinion, R: Rack}
+		if Report {
+			fmt.Fprintf(os.Stderr, "Pinion\n%s%s\nRack\n%s\nPair\n%s",
+				Pinion, mass(Pinion, Face, Density), Rack, RackPair)
+		}
+		warnings := RackPair.CheckInterference().Warnings("Pinion")
+		if hasFormat(Formats, "gcode") {
+			warnings = append(warnings, rootGap("Pinion", Pinion.GetRootGap(),
+				Mill.ToolDia)...)
+			warnings = append(warnings, rootGap("Rack", Rack.GetRootGap(),
+				Mill.ToolDia)...)
+		}
+		for _, w := range warnings {
+			fmt.Fprintln(os.Stderr, "Warning:", w)
+		}
+		for _, Format := range Formats {
+			if Format == "stl" {
+				err = solid(FileName, ASCII, Face, []gear.Gear{Pinion}, Rack)
+			} else if Format == "scad" {
+				err = write(FileName, ".scad", func(w io.Writer) error {
+					return scad.PlotRack(w, RackPair, Rotation, Face)
+				})
+			} else if Format == "gcode" {
+				err = mill(FileName, Mill, Pinion.Contours(), Rack.Contours())
+			} else if Cut {
+				err = cut(Format, FileName, SVGOptions, Pinion.Contours(),
+					Rack.Contours())
+			} else {
+				err = output(Format, FileName, func(w io.Writer) error {
+					return plot.PlotRack(w, RackPair, Rotation, SVGOptions)
+				}, func(w io.Writer) error {
+					return dxf.PlotRack(w, RackPair, Rotation)
+				})
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
 
 	Ratio = float64(DrivenTeeth) / float64(DriveTeeth)
+	if Internal && DrivenTeeth <= DriveTeeth {
+		fmt.Fprintln(os.Stderr,
+			"Error: an internal gear needs more teeth than its pinion")
+		os.Exit(1)
+	}
 
 	var Gear1 gear.Gear
-	Gear1.Pd = (1 / (Ratio + 1)) * Centres * 2
-	Gear1.N = DriveTeeth
-	Gear1.A = PressureAngle
-	Gear1.B = Backlash
-
 	var Gear2 gear.Gear
-	Gear2.Pd = (Ratio / (Ratio + 1)) * Centres * 2
-	Gear2.N = DrivenTeeth
-	Gear2.A = PressureAngle
-	Gear2.B = Backlash
+	if Module > 0 {
+		// Work straight from the module so the pitch diameters are exact.
+		Gear1 = gear.FromNormal(Module, DriveTeeth, PressureAngle, Backlash,
+			Helix)
+		Gear2 = gear.FromNormal(Module, DrivenTeeth, PressureAngle, Backlash,
+			Helix)
+		Gear1.X = Shift1
+		Gear2.X = Shift2
+		Gear2.Internal = Internal
+		if flagGiven("c") {
+			// Take up the difference from the standard centre distance by
+			// shifting the second gear.
+			if Internal {
+				Gear2.X = gear.ShiftForCentres(Gear1, Gear2, Centres) + Shift1
+			} else {
+				Gear2.X = gear.ShiftForCentres(Gear1, Gear2, Centres) - Shift1
+			}
+			if math.IsNaN(Gear2.X) {
+				fmt.Fprintf(os.Stderr, "Error: the gears cannot mesh at "+
+					"centres of %.3f mm\n", Centres)
+				os.Exit(1)
+			}
+		} else {
+			Centres = gear.WorkingCentreDistance(Gear1, Gear2)
+		}
+	} else {
+		// The centres are the sum of the pitch radii, or their difference
+		// when the pinion runs inside an internal gear.
+		div := Ratio + 1
+		if Internal {
+			div = Ratio - 1
+		}
+		Gear1.Pd = (1 / div) * Centres * 2
+		Gear1.N = DriveTeeth
+		Gear1.A = gear.TransverseAngle(PressureAngle, Helix)
+		Gear1.B = Backlash
+		Gear1.X = Shift1
+		Gear1.Helix = Helix
+
+		Gear2.Pd = (Ratio / div) * Centres * 2
+		Gear2.N = DrivenTeeth
+		Gear2.A = Gear1.A
+		Gear2.B = Backlash
+		Gear2.Helix = Helix
+		Gear2.X = Shift2
+		Gear2.Internal = Internal
 
-	plot.Plot(Gear1, Gear2, Rotation, FileName)
+		// Profile shift pushes the gears apart, so scale both gears down
+		// until they mesh at the requested centres.
+		scale := Centres / gear.WorkingCentreDistance(Gear1, Gear2)
+		Gear1.Pd *= scale
+		Gear2.Pd *= scale
+	}
+
+	Gear1.Rf = TipRadius
+	Gear2.Rf = TipRadius
+	Gear2.Rim = Rim
+	Gear1.Face, Gear2.Face = Face, Face
+	Gear1.Kerf, Gear2.Kerf = Kerf, Kerf
+	Gear1.Hand = Hand
+	Gear2.Hand = Hand.Opposite()
+	if Internal {
+		Gear2.Hand = Hand
+	}
+	ok := valid("first gear", Gear1)
+	ok = valid("second gear", Gear2) && ok
+	if !ok {
+		os.Exit(1)
+	}
+	Gear1.Bore, err = bore(Gear1, Bore1, BoreType1, Flat1)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: first gear bore:", err)
+		os.Exit(1)
+	}
+	Gear1.Web, err = web(Gear1, Web1, WebN1, WebSize1, WebRim1,
+		WebHub1, WebPCD1)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: first gear web:", err)
+		os.Exit(1)
+	}
+	if !Internal {
+		Gear2.Bore, err = bore(Gear2, Bore2, BoreType2, Flat2)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: second gear bore:", err)
+			os.Exit(1)
+		}
+		Gear2.Web, err = web(Gear2, Web2, WebN2, WebSize2, WebRim2,
+			WebHub2, WebPCD2)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: second gear web:", err)
+			os.Exit(1)
+		}
+	}
+
+	Pair := gear.Pair{G1: Gear1, G2: Gear2, C: Centres}
+	if err := Pair.CheckHelix(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if Report {
+		fmt.Fprintf(os.Stderr, "First Gear\n%s%s\nSecond Gear\n%s%s\nPair\n%s",
+			Gear1, mass(Gear1, Face, Density), Gear2, mass(Gear2, Face, Density),
+			Pair)
+	}
+
+	// Warn about any problems with the design, but draw it anyway.
+	check1, check2 := gear.CheckInterference(Gear1, Gear2)
+	warnings := append(check1.Warnings("First gear"),
+		check2.Warnings("Second gear")...)
+	if hasFormat(Formats, "gcode") {
+		warnings = append(warnings, rootGap("First gear", Gear1.GetRootGap(),
+			Mill.ToolDia)...)
+		warnings = append(warnings, rootGap("Second gear", Gear2.GetRootGap(),
+			Mill.ToolDia)...)
+	}
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", w)
+	}
+
+	for _, Format := range Formats {
+		if Format == "stl" {
+			err = solid(FileName, ASCII, Face, []gear.Gear{Gear1, Gear2})
+		} else if Format == "scad" {
+			err = write(FileName, ".scad", func(w io.Writer) error {
+				return scad.Plot(w, Pair, Rotation)
+			})
+		} else if Format == "gcode" {
+			err = mill(FileName, Mill, Gear1.Contours(), Gear2.Contours())
+		} else if Cut {
+			err = cut(Format, FileName, SVGOptions, Gear1.Contours(),
+				Gear2.Contours())
+		} else {
+			err = output(Format, FileName, func(w io.Writer) error {
+				return plot.Plot(w, Pair, Rotation, SVGOptions)
+			}, func(w io.Writer) error {
+				return dxf.Plot(w, Pair, Rotation)
+			})
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	}
 }
@@ -0,0 +1,54 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestTeeth(t *testing.T) {
+	// Run main with the arguments given, when the test is run again below.
+	if args := os.Getenv("GEARGEN_ARGS"); args != "" {
+		os.Args = append([]string{"GearGen"}, strings.Fields(args)...)
+		main()
+		return
+	}
+	// Too few teeth is the one error, not the size worked out from them.
+	for _, c := range []struct {
+		args, want string
+	}{
+		{"-n1 0", "Error: first gear: N = 0, must be at least 5"},
+		{"-m 2 -n1 0", "Error: first gear: N = 0, must be at least 5"},
+		{"-m 2 -n1 12 -n2 3", "Error: second gear: N = 3, must be at least 5"},
+		{"-n1 0 -rack 10", "Error: pinion: N = 0, must be at least 5"},
+		{"-m 1 -n1 0 -n2 30 -planets 3", "Error: sun: N = 0, must be at " +
+			"least 5"},
+	} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestTeeth$")
+		cmd.Env = append(os.Environ(), "GEARGEN_ARGS="+c.args)
+		out, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Errorf("GearGen %s succeeded", c.args)
+		}
+		if got := strings.TrimSpace(string(out)); got != c.want {
+			t.Errorf("GearGen %s printed %q, want %q", c.args, got, c.want)
+		}
+	}
+}
@@ -19,10 +19,10 @@ package plot
 
 import (
 	"fmt"
-	"github.com/ajstarks/svgo"
 	"github.com/stuphi/GearGen/gear"
+	"io"
 	"math"
-	"os"
+	"strings"
 )
 
 // Some useful conversions. Not all are used.
@@ -31,48 +31,89 @@ const (
 	DegToRad  = math.Pi / 180.0
 	RadToGrad = 200.0 / math.Pi
 	GradToDeg = math.Pi / 200.0
-	// This factor is used as the svg library only accepts int. Therefore we need
-	// to multiply all dimensions by this factor and use a viewport in the svg
-	// document to be able to get sub mm acuracy. By using 1000 here, each unit
-	// will be 0.001 mm in size.
-	factor = 1000
+	// Number of decimal places written for each dimension unless the options
+	// say otherwise. Three places gives each unit as 0.001 mm.
+	DefaultPrecision = 3
+	// The precision to give in the options for whole units, with no
+	// decimal places.
+	WholeUnits = -1
 )
 
+// Options that change how a drawing is made.
+type Options struct {
+	// Add the contact ratio of the pair to the drawing.
+	Annotate bool
+	// If more than zero the drawing is animated, with the first gear
+	// turning once every Period seconds.
+	Period float64
+	// Number of decimal places for each dimension, 0 for DefaultPrecision
+	// or WholeUnits for none.
+	Precision int
+	// CSS for each line type named in Styles, in place of the default.
+	Styles map[string]string
+}
+
+// The line types that can be styled.
+var Styles = []string{"solid", "dash", "thin", "grid", "anott"}
+
+// Plot the closed contours cs to w, ready to cut. Each contour is a single
+// path with nothing else on the drawing, and the contours are written in the
+// order given. The y axis points up, as it does in CAD.
+func Cut(w io.Writer, cs []gear.Outline, opts Options) error {
+	if len(cs) == 0 {
+		return fmt.Errorf("there are no contours to cut")
+	}
+	border := 5.0
+	min := gear.Point{X: math.Inf(1), Y: math.Inf(1)}
+	max := gear.Point{X: math.Inf(-1), Y: math.Inf(-1)}
+	for _, o := range cs {
+		lo, hi := o.Bounds()
+		min = gear.Point{X: math.Min(min.X, lo.X), Y: math.Min(min.Y, lo.Y)}
+		max = gear.Point{X: math.Max(max.X, hi.X), Y: math.Max(max.Y, hi.Y)}
+	}
+	width := int(math.Ceil(max.X - min.X + 2*border))
+	height := int(math.Ceil(max.Y - min.Y + 2*border))
+	canvas := start(w, width, height, opts)
+	for _, o := range cs {
+		canvas.path(o.Mirror().Translate(border-min.X, border+max.Y),
+			canvas.style("solid"))
+	}
+	canvas.end()
+	return canvas.err()
+}
+
 // Return the apropriate style string for the requested line type.
 func style(s string) string {
 	switch s {
 	case "dash":
-		return fmt.Sprintf("fill:none; stroke-width:%d; stroke:black; stroke-dasharray:%d,%d,%d,%d",
-			int(0.1*factor), 3*factor, 1*factor, 1*factor, 1*factor)
+		return "fill:none; stroke-width:0.1; stroke:black; " +
+			"stroke-dasharray:3,1,1,1"
 	case "solid":
-		return fmt.Sprintf("fill:none; stroke-width:%d; stroke:black",
-			int(0.25*factor))
+		return "fill:none; stroke-width:0.25; stroke:black"
 	case "thin":
-		return fmt.Sprintf("fill:none; stroke-width:%d; stroke:black",
-			int(0.1*factor))
+		return "fill:none; stroke-width:0.1; stroke:black"
 	case "grid":
-		return fmt.Sprintf("fill:none; stroke-width:%d; stroke:lightgrey",
-			int(0.1*factor))
+		return "fill:none; stroke-width:0.1; stroke:lightgrey"
 	case "anott":
-		return fmt.Sprintf("text-anchor:middle;font-size:%d;fill:#888888;fill-opacity:0.5",
-			int(5*factor))
+		return "text-anchor:middle;font-size:5;fill:#888888;fill-opacity:0.5"
 
 	}
 	return "fill:none; stroke:none"
 }
 
 // Plot a grid on our canvas.
-// cx and cy are the centre of the first gear in drawing units
-// width and height are the size of the drawing in drawing units
+// cx and cy are the centre of the first gear in mm
+// width and height are the size of the drawing in mm
 // canvas is the canvas to draw to.
-func plotGrid(cx int, cy int, width int, height int, canvas *svg.SVG) {
-	// Set the spacing in drawing units
-	spaceing := 5 * factor // 5mm
+func plotGrid(cx float64, cy float64, width float64, height float64,
+	canvas *canvas) {
+	// Set the spacing in mm
+	spaceing := 5.0
 
 	// Calculate the start of the grid such that the centre of the first gear will
 	// be on the grid
-	gx := int(math.Mod(float64(cx), float64(spaceing)))
-	gy := int(math.Mod(float64(cy), float64(spaceing)))
+	gx := math.Mod(cx, spaceing)
+	gy := math.Mod(cy, spaceing)
 
 	// Set the width and height so that there is an even border all round.
 	// could do with trying to sort out the width of the canvas so that the right
@@ -80,178 +121,292 @@ func plotGrid(cx int, cy int, width int, height int, canvas *svg.SVG) {
 	gw := width - (2 * gx)
 	gh := height - (2 * gy)
 
-	canvas.Grid(gx, gy, gw, gh, spaceing, style("grid"))
-}
-
-// Calculate the involute angle at witch point the involute reaches the
-// requested radius
-func involuteIntersectAngle(br, r float64) float64 {
-	return math.Sqrt(math.Pow(r/br, 2) - 1)
+	for x := gx; x <= gx+gw+1e-9; x += spaceing {
+		canvas.line(gear.Point{X: x, Y: gy}, gear.Point{X: x, Y: gy + gh},
+			canvas.style("grid"))
+	}
+	for y := gy; y <= gy+gh+1e-9; y += spaceing {
+		canvas.line(gear.Point{X: gx, Y: y}, gear.Point{X: gx + gw, Y: y},
+			canvas.style("grid"))
+	}
 }
 
-// Calculate the xy cordinates of the involute at a given angle
-func xyLocation(br, ang float64) (float64, float64) {
-	x := br * (math.Cos(ang) + ang*math.Sin(ang))
-	y := br * (math.Sin(ang) - ang*math.Cos(ang))
-	return x, y
+// A repeating movement of part of the drawing, given as the values an SVG
+// transform of type kind steps through evenly over dur seconds. A zero dur
+// means the part stays still.
+type motion struct {
+	kind   string
+	values []string
+	dur    float64
 }
 
-// Given an xy point, calculate the new position if rotated by given angle
-func rotXY(x, y int, ang float64) (int, int) {
-	var xf, yf float64
-	xf = float64(x)
-	yf = float64(y)
-	hf := math.Sqrt(math.Pow(xf, 2) + math.Pow(yf, 2))
-	a := math.Atan(yf/xf) + ang
-	xf = math.Cos(a) * hf
-	yf = math.Sin(a) * hf
-	return int(xf), int(yf)
+// Add the motion to the group that has just been started on the canvas.
+func (m motion) plot(canvas *canvas) {
+	if m.dur <= 0 {
+		return
+	}
+	fmt.Fprintf(canvas.w, "<animateTransform attributeName=\"transform\" "+
+		"type=\"%s\" additive=\"sum\" values=\"%s\" dur=\"%gs\" "+
+		"repeatCount=\"indefinite\" />\n", m.kind, strings.Join(m.values, ";"),
+		m.dur)
 }
 
-// Plot the involute gear profile for a single tooth
-func plotInvCurve(g gear.Gear, canvas *svg.SVG) {
-	var px []int
-	var py []int
-	var pyi []int
-	var x, y float64
-	var r, sr, ang float64
-	var offsetAng float64
-	br := g.GetBaseCircleDia() * factor / 2 // Base Radius
-	or := g.GetOutsideDia() * factor / 2    // Outside Radius
-	rr := g.GetRootCircleDia() * factor / 2 // Root Radius
-	pr := g.Pd * factor / 2                 // Pitch Circle Radius
-	ang = involuteIntersectAngle(br, pr)
-	x, y = xyLocation(br, ang)
-	offsetAng = math.Atan(y/x) * -1
-	offsetAng += (math.Pi / (float64(g.N) / 2.0)) / -4.0
-	if rr > br {
-		sr = rr
-	} else {
-		sr = br
+// Plot a complete gear centred on c rotated by angle rot, and turning with
+// motion m. The teeth are drawn as a single closed path.
+func plotGear(c gear.Point, rot float64, g gear.Gear, m motion,
+	canvas *canvas) {
+	canvas.group(fmt.Sprintf("translate(%s)", canvas.point(c)))
+	canvas.circle(gear.Point{}, g.Pd/2, canvas.style("dash"))
+	if g.Internal {
+		canvas.circle(gear.Point{}, g.GetRimDia()/2, canvas.style("solid"))
 	}
-	rinc := (or - sr) / 100
-	for r = sr; r <= or; r += rinc {
-		ang = involuteIntersectAngle(br, r)
-		x, y = xyLocation(br, ang)
-		px = append(px, int(x))
-		py = append(py, int(y))
-		pyi = append(pyi, int(y)*-1)
+	cntrLen := g.GetOutsideDia() / 8
+	canvas.line(gear.Point{X: -cntrLen}, gear.Point{X: cntrLen}, canvas.style("solid"))
+	canvas.line(gear.Point{Y: -cntrLen}, gear.Point{Y: cntrLen}, canvas.style("solid"))
+	canvas.group(fmt.Sprintf("rotate(%s)", canvas.num(rot)))
+	m.plot(canvas)
+	for i := 0; i < g.N; i++ {
+		ang := (2 * math.Pi / float64(g.N)) * float64(i)
+		canvas.line(gear.Point{X: g.GetRootCircleDia() / 2}.Rotate(ang),
+			gear.Point{X: g.GetTipDia() / 2}.Rotate(ang), canvas.style("dash"))
 	}
-	if br > rr {
-		px = append([]int{int(rr)}, px...)
-		py = append([]int{0}, py...)
-		pyi = append([]int{0}, pyi...)
+	canvas.path(g.Outline(), canvas.style("solid"))
+	for _, h := range g.Holes() {
+		canvas.path(h, canvas.style("solid"))
 	}
-	canvas.Gtransform(fmt.Sprintf("rotate(%0.4f)", offsetAng*RadToDeg))
-	canvas.Polyline(px, py, style("solid"))
-	canvas.Gend()
-	canvas.Gtransform(fmt.Sprintf("rotate(%0.4f)", offsetAng*RadToDeg*-1.0))
-	canvas.Polyline(px, pyi, style("solid"))
-	canvas.Gend()
-	sx, sy := rotXY(px[len(px)-1], py[len(py)-1], offsetAng)
-	ex, ey := rotXY(px[len(px)-1], pyi[len(pyi)-1], offsetAng*-1.0)
-	canvas.Path(fmt.Sprintf("M%d,%d A%d,%d 0 0 1 %d,%d",
-		sx, sy, int(or), int(or),
-		ex, ey), style("solid"))
-
-	sx, sy = rotXY(px[0], py[0], -offsetAng)
-	ex, ey = rotXY(px[0], py[0], ((2*math.Pi)/float64(g.N))+offsetAng)
-	canvas.Path(fmt.Sprintf("M%d,%d A%d,%d 0 0 1 %d,%d",
-		sx, sy, int(rr), int(rr),
-		ex, ey), style("solid"))
-}
-
-// Plot a complete gear at cx,cy rotated by angle rot.
-func plotGear(cx int, cy int, rot float64, g gear.Gear, canvas *svg.SVG) {
-	canvas.Gtransform(fmt.Sprintf("translate(%d, %d)", cx, cy))
-	canvas.Circle(0, 0, int(g.Pd*factor/2), style("dash"))
-	cntrLen := int(g.GetOutsideDia() * factor / 8)
-	canvas.Line(-cntrLen, 0, cntrLen, 0, style("solid"))
-	canvas.Line(0, -cntrLen, 0, cntrLen, style("solid"))
-	canvas.Gtransform(fmt.Sprintf("rotate(%0.3f)", rot))
-	for i := 0; i < g.N; i++ {
-		canvas.Line(int((math.Cos((360/float64(g.N))*float64(i)*DegToRad) *
-			g.GetRootCircleDia() * factor / 2)),
-			int((math.Sin((360/float64(g.N))*float64(i)*DegToRad) *
-				g.GetRootCircleDia() * factor / 2)),
-			int((math.Cos((360/float64(g.N))*float64(i)*DegToRad) *
-				g.GetOutsideDia() * factor / 2)),
-			int((math.Sin((360/float64(g.N))*float64(i)*DegToRad) *
-				g.GetOutsideDia() * factor / 2)),
-			style("dash"))
-		canvas.Gtransform(fmt.Sprintf("rotate(%f)", 360.0/float64(g.N)*float64(i)))
-		plotInvCurve(g, canvas)
-		canvas.Gend()
+	canvas.groupEnd()
+	// The pinion sits over the centre of an internal gear, so move the text
+	// down out of its way.
+	ty := 0.0
+	if g.Internal {
+		ty = g.GetTipDia() * 0.3
 	}
-	canvas.Gend()
 	anottext := fmt.Sprintf("Pitch Dia: %0.1f", g.Pd)
-	canvas.Text(0, -1 * factor, anottext, style("anott"))
+	canvas.text(gear.Point{Y: ty - 1}, anottext, canvas.style("anott"))
 	anottext = fmt.Sprintf("Teeth: %d", g.N)
-	canvas.Text(0, 5 * factor, anottext, style("anott"))
+	canvas.text(gear.Point{Y: ty + 5}, anottext, canvas.style("anott"))
 	anottext = fmt.Sprintf("Pressure Angle: %0.1f", g.A)
-	canvas.Text(0, 11 * factor, anottext, style("anott"))
-	canvas.Gend()
+	canvas.text(gear.Point{Y: ty + 11}, anottext, canvas.style("anott"))
+	anottext = fmt.Sprintf("Module: %0.3f", g.GetModule())
+	canvas.text(gear.Point{Y: ty + 17}, anottext, canvas.style("anott"))
+	canvas.groupEnd()
 }
 
-// Plot the complete drawing of gears g1 and g2 to file fname or stdout if no
-// file is given.
+// Plot the complete drawing of the pair of gears p to w.
 // rotfrac represents the percentage of one tooth to rotate both gears. Used
 // to be able to draw the gears at different stages of engagment.
-func Plot(g1, g2 gear.Gear, rotfrac int, fname string) {
+func Plot(w io.Writer, p gear.Pair, rotfrac int, opts Options) error {
+	if err := p.Check(); err != nil {
+		return err
+	}
 	var width, height int
+	g1, g2 := p.G1, p.G2
 
 	border := 5.0
 
 	// Determin the size of our canvas.
-	if g1.Pd > g2.Pd {
+	if g1.GetOutsideDia() > g2.GetOutsideDia() {
 		height = int(g1.GetOutsideDia() + (2 * border))
 	} else {
 		height = int(g2.GetOutsideDia() + (2 * border))
 	}
-	width = int((g1.Pd + g2.Pd + g1.GetAddendum() + g2.GetAddendum()) +
-		(2 * border))
+	offset := p.GetOffset()
+	left := math.Min(-g1.GetOutsideDia()/2, offset-g2.GetOutsideDia()/2)
+	right := math.Max(g1.GetOutsideDia()/2, offset+g2.GetOutsideDia()/2)
+	width = int((right - left) + (2 * border))
 
-	centerDist := (g1.Pd + g2.Pd) / 2
-	cx := int((border + (g1.GetOutsideDia() / 2.0)) * factor)
-	cy := height * factor / 2
-	var canvas *svg.SVG
-	var f *os.File
-	var err error
-	// If we are writing to file, open the file or quit if there is an error.
-	if fname != "" {
-		f, err = os.Create(fmt.Sprintf("%s.svg", fname))
-		if err != nil {
-			fmt.Println("Something failed creating file!")
-			os.Exit(1)
-		}
-		canvas = svg.New(f)
-	} else {
-		// If we are not using a file, send output to stdout.
-		canvas = svg.New(os.Stdout)
+	cx := border - left
+	cy := float64(height) / 2
+	canvas := start(w, width, height, opts)
+	plotGrid(cx, cy, float64(width), float64(height), canvas)
+	rot1, rot2 := p.GetRotations(float64(rotfrac))
+	// Find how far the second gear turns for each turn of the first, which
+	// brings it back to a matching tooth.
+	_, next2 := p.GetRotations(float64(rotfrac + 100))
+	turn2 := (next2 - rot2) * float64(g1.N)
+	plotGear(gear.Point{X: cx, Y: cy}, rot1, g1, motion{"rotate",
+		[]string{"0", "360"}, opts.Period}, canvas)
+	plotGear(gear.Point{X: cx + offset, Y: cy}, rot2, g2, motion{"rotate",
+		[]string{"0", canvas.num(turn2)}, opts.Period}, canvas)
+
+	if opts.Annotate {
+		anottext := fmt.Sprintf("Contact Ratio: %0.3f  Path of Contact: %0.3f"+
+			"  Base Pitch: %0.3f", p.GetContactRatio(), p.GetPathOfContact(),
+			p.GetBasePitch())
+		canvas.text(gear.Point{X: float64(width) / 2, Y: float64(height - 8)},
+			anottext, canvas.style("anott"))
 	}
 
-	// Setup canvas so that each drawing unit is 0.01mm.
-	canvas.StartviewUnit(width, height, "mm", 0, 0, width*factor, height*
-		factor)
-	plotGrid(cx, cy, width*factor, height*factor, canvas)
-	rot := 0.0 + ((float64(rotfrac) / 100) * (360 / float64(g1.N)))
-	plotGear(cx, cy, rot, g1, canvas)
-	cx = cx + int(centerDist*factor)
-	if math.Mod(float64(g2.N), 2) == 0 {
-		rot = 180.0 / float64(g2.N)
-	} else {
-		rot = 0.0
+	return finish(canvas, width, height)
+}
+
+// Plot the complete drawing of the rack and pinion p to w. The pinion is
+// drawn above the rack.
+// rotfrac represents the percentage of one tooth to rotate the pinion, which
+// moves the rack along with it.
+// When animated, the rack and pinion run back and forth with the pinion
+// turning at the speed given by the options.
+func PlotRack(w io.Writer, p gear.RackPair, rotfrac int,
+	opts Options) error {
+	if err := p.Check(); err != nil {
+		return err
+	}
+	g, r := p.G, p.R
+	border := 5.0
+	h := p.GetCentreHeight()
+	// Leave room for the rack to travel by up to a pitch and a half.
+	width := int(math.Max(r.GetLength()+3*r.GetCircularPitch(),
+		g.GetOutsideDia()) + (2 * border))
+	height := int(g.GetOutsideDia()/2 + h + r.GetDedendum() + r.GetBack() +
+		(2 * border) + 10)
+
+	cx := float64(width) / 2
+	cy := border + g.GetOutsideDia()/2
+	canvas := start(w, width, height, opts)
+	plotGrid(cx, cy, float64(width), float64(height), canvas)
+	rot, travel := p.GetRotation(float64(rotfrac))
+	// Run a quarter of the length of the rack each way, which keeps it in
+	// mesh with the pinion.
+	k := math.Max(1, float64(r.N/4))
+	ang := canvas.num(k * 360 / float64(g.N))
+	d := canvas.num(k * r.GetCircularPitch())
+	dur := opts.Period * 4 * k / float64(g.N)
+	// The drawing has y pointing down, so the pinion turns the other way.
+	plotGear(gear.Point{X: cx, Y: cy}, -rot, g, motion{"rotate",
+		[]string{"0", "-" + ang, "0", ang, "0"}, dur}, canvas)
+	canvas.group(fmt.Sprintf("translate(%s) scale(1, -1)",
+		canvas.point(gear.Point{X: cx + travel, Y: cy + h})))
+	motion{"translate", []string{"0,0", d + ",0", "0,0", "-" + d + ",0",
+		"0,0"}, dur}.plot(canvas)
+	l := r.GetLength() / 2
+	canvas.line(gear.Point{X: -l}, gear.Point{X: l}, canvas.style("dash"))
+	canvas.path(r.Outline(), canvas.style("solid"))
+	canvas.groupEnd()
+
+	if opts.Annotate {
+		anottext := fmt.Sprintf("Contact Ratio: %0.3f  Path of Contact: %0.3f"+
+			"  Base Pitch: %0.3f", p.GetContactRatio(), p.GetPathOfContact(),
+			p.GetBasePitch())
+		canvas.text(gear.Point{X: float64(width) / 2, Y: float64(height - 8)},
+			anottext, canvas.style("anott"))
+	}
+
+	return finish(canvas, width, height)
+}
+
+// Plot the complete drawing of the gear train t to w, with the overall ratio
+// beneath it. Unlike Plot, the y axis points up, so the angles of the shafts
+// are anticlockwise as given. rotfrac is the percentage of one tooth to turn
+// the first gear, and when animated every gear turns at its own speed.
+func PlotTrain(w io.Writer, t gear.Train, rotfrac int, opts Options) error {
+	ps, err := t.Layout(float64(rotfrac))
+	if err != nil {
+		return err
+	}
+	border := 5.0
+	min := gear.Point{X: math.Inf(1), Y: math.Inf(1)}
+	max := gear.Point{X: math.Inf(-1), Y: math.Inf(-1)}
+	for i, p := range ps {
+		r := t.Gears[i].Gear.GetOutsideDia() / 2
+		min = gear.Point{X: math.Min(min.X, p.Centre.X-r),
+			Y: math.Min(min.Y, p.Centre.Y-r)}
+		max = gear.Point{X: math.Max(max.X, p.Centre.X+r),
+			Y: math.Max(max.Y, p.Centre.Y+r)}
 	}
-	rot -= (float64(rotfrac) / 100) * (360 / float64(g2.N))
-	plotGear(cx, cy, rot, g2, canvas)
+	// Leave room below for the ratio and the signature.
+	width := int(math.Ceil(max.X - min.X + 2*border))
+	height := int(math.Ceil(max.Y - min.Y + 2*border + 10))
+	cx := border - min.X
+	cy := border + max.Y
+	canvas := start(w, width, height, opts)
+	plotGrid(cx, cy, float64(width), float64(height), canvas)
+	for i, p := range ps {
+		// The drawing has y pointing down, so each gear turns the other way.
+		plotGear(gear.Point{X: cx + p.Centre.X, Y: cy - p.Centre.Y}, -p.Rot,
+			t.Gears[i].Gear, motion{"rotate", []string{"0",
+				canvas.num(-360 * p.Speed)}, opts.Period}, canvas)
+	}
+	anottext := fmt.Sprintf("Overall Ratio: %0.4f", t.GetRatio())
+	if opts.Annotate {
+		for i, p := range t.Pairs() {
+			anottext += fmt.Sprintf("  Contact Ratio %d: %0.3f", i+1,
+				p.GetContactRatio())
+		}
+	}
+	canvas.text(gear.Point{X: float64(width) / 2, Y: float64(height - 8)},
+		anottext, canvas.style("anott"))
+	return finish(canvas, width, height)
+}
 
-	canvas.Text((width / 2) * factor, (height - 2) * factor,
-		"Generated by GearGen. http://github/stuphi/GearGen", style("anott"))
+// Plot the complete drawing of the planetary set p to w, with the ratio
+// beneath it. As for PlotTrain, the y axis points up. rotfrac is the
+// percentage of one tooth to turn the input with member fixed held, and when
+// animated the input turns once every period, with the planets carried round
+// the sun.
+func PlotPlanetary(w io.Writer, p gear.Planetary, fixed gear.Member,
+	rotfrac int, opts Options) error {
+	l, err := p.Layout(fixed, float64(rotfrac))
+	if err != nil {
+		return err
+	}
+	border := 5.0
+	r := p.Ring.GetOutsideDia() / 2
+	// Leave room below for the ratio and the signature.
+	width := int(math.Ceil(2*r + 2*border))
+	height := int(math.Ceil(2*r + 2*border + 10))
+	c := gear.Point{X: border + r, Y: border + r}
+	canvas := start(w, width, height, opts)
+	plotGrid(c.X, c.Y, float64(width), float64(height), canvas)
+	spin := func(speed float64) motion {
+		return motion{"rotate", []string{"0", canvas.num(-360 * speed)},
+			opts.Period}
+	}
+	// The drawing has y pointing down, so each gear turns the other way.
+	plotGear(c, -l.Ring.Rot, p.Ring, spin(l.Ring.Speed), canvas)
+	plotGear(c, -l.Sun.Rot, p.Sun, spin(l.Sun.Speed), canvas)
+	// The planets turn on the carrier as it goes round.
+	canvas.group(fmt.Sprintf("translate(%s)", canvas.point(c)))
+	canvas.group("rotate(0)")
+	spin(l.Carrier.Speed).plot(canvas)
+	canvas.circle(gear.Point{}, p.GetCentreDistance(), canvas.style("thin"))
+	for _, pp := range l.Planets {
+		centre := gear.Point{X: pp.Centre.X, Y: -pp.Centre.Y}
+		canvas.line(gear.Point{}, centre, canvas.style("thin"))
+		plotGear(centre, -pp.Rot, p.Planet, spin(pp.Speed-l.Carrier.Speed),
+			canvas)
+	}
+	canvas.groupEnd()
+	canvas.groupEnd()
+	in, out := fixed.Drive()
+	anottext := fmt.Sprintf("Ratio: %0.4f, %s fixed, %s in, %s out",
+		p.GetRatio(fixed), fixed, in, out)
+	if opts.Annotate {
+		anottext += fmt.Sprintf("  Contact Ratio Sun: %0.3f  Ring: %0.3f",
+			p.SunPair().GetContactRatio(), p.RingPair().GetContactRatio())
+	}
+	canvas.text(gear.Point{X: float64(width) / 2, Y: float64(height - 8)},
+		anottext, canvas.style("anott"))
+	return finish(canvas, width, height)
+}
 
-	canvas.End()
-	// Cleanly close file, if we are writing to file.
-	if f != nil {
-		f.Sync()
-		f.Close()
+// Start a drawing width by height mm on a new canvas writing to w.
+func start(w io.Writer, width, height int, opts Options) *canvas {
+	c := &canvas{w: &errWriter{w: w}, prec: opts.Precision,
+		styles: opts.Styles}
+	switch {
+	case c.prec == WholeUnits:
+		c.prec = 0
+	case c.prec <= 0:
+		c.prec = DefaultPrecision
 	}
+	c.start(float64(width), float64(height))
+	return c
+}
+
+// Sign and end the drawing, and return the first error writing it.
+func finish(canvas *canvas, width, height int) error {
+	canvas.text(gear.Point{X: float64(width) / 2, Y: float64(height - 2)},
+		"Generated by GearGen. http://github/stuphi/GearGen", canvas.style("anott"))
+	canvas.end()
+	return canvas.err()
 }
@@ -17,8 +17,12 @@
 package plot
 
 import (
-	"fmt"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"github.com/stuphi/GearGen/gear"
 	"math"
+	"strings"
 	"testing"
 )
 
@@ -31,36 +35,205 @@ func RoundPlus(f float64, places int) float64 {
 	return Round(f*shift) / shift
 }
 
-func TestInvoluteIntersectAngle(t *testing.T) {
-	cases := []struct{ inbr, inr, want float64 }{
-		{100, 110, 0.458258},
-		{200, 220, 0.458258},
-		{100, 100, 0.0},
-		{100, 200, 1.732051},
-		{100, 300, 2.828427},
+func TestPathData(t *testing.T) {
+	o := gear.Outline{
+		{Kind: gear.Line, Points: []gear.Point{{X: 0, Y: 0}, {X: 1, Y: 0}}},
+		{Kind: gear.Arc, Points: []gear.Point{{X: 1, Y: 0}, {X: 0, Y: 1}}, Radius: 1},
+		{Kind: gear.Involute, Points: []gear.Point{{X: 0, Y: 1}, {X: 0, Y: 0.5}, {X: 0, Y: 0}}},
+	}
+	c := canvas{prec: 3}
+	want := "M0,0 L1,0 A1,1 0 0 1 0,1 L0,0.5 0,0 Z"
+	if got := c.pathData(o); got != want {
+		t.Errorf("pathData() == %q, want %q", got, want)
+	}
+}
+
+func TestNum(t *testing.T) {
+	cases := []struct {
+		in   float64
+		prec int
+		want string
+	}{
+		{1.23456, 3, "1.235"},
+		{1.5, 3, "1.5"},
+		{2, 3, "2"},
+		{-0.0001, 3, "0"},
+		{123.456789, 6, "123.456789"},
+		{10, 0, "10"},
 	}
 	for _, c := range cases {
-		got := involuteIntersectAngle(c.inbr, c.inr)
-		if fmt.Sprintf("%0.6f", got) != fmt.Sprintf("%0.6f", c.want) {
-			t.Errorf("involuteIntersectAngle(br %f, r %f) == %f, want %f", c.inbr, c.inr, got, c.want)
+		cv := canvas{prec: c.prec}
+		if got := cv.num(c.in); got != c.want {
+			t.Errorf("num(%v) with %d places == %q, want %q", c.in, c.prec, got,
+				c.want)
 		}
 	}
 }
 
-func TestXyLocation(t *testing.T) {
-	cases := []struct{ inbr, inang, wantX, wantY float64 }{
-		{100, 0.458258, 109.955165, 3.140951},
-		{200, 0.458258, 219.910331, 6.281902},
-		{100, 0.0, 100, 0},
-		{100, 1.732051, 154.902371, 126.511906},
-		{100, 2.828427, -8.000432, 299.893291},
+func TestPlotGearPath(t *testing.T) {
+	// Each gear is drawn as one closed path, the same every time.
+	g := gear.FromModule(1, 12, 20, 0)
+	var b1, b2 bytes.Buffer
+	plotGear(gear.Point{X: 10, Y: 10}, 7.5, g, motion{}, &canvas{w: &b1, prec: 4})
+	plotGear(gear.Point{X: 10, Y: 10}, 7.5, g, motion{}, &canvas{w: &b2, prec: 4})
+	if b1.String() != b2.String() {
+		t.Errorf("plotGear() output differs between runs")
 	}
-	for _, c := range cases {
-		gotX, gotY := xyLocation(c.inbr, c.inang)
-		if fmt.Sprintf("%0.6f,%0.6f", gotX, gotY) !=
-			fmt.Sprintf("%0.6f,%0.6f", c.wantX, c.wantY) {
-			t.Errorf("xyLocation(br %f, ang %f) == %f,%f want %f,%f", c.inbr, c.inang,
-				gotX, gotY, c.wantX, c.wantY)
+	if got := strings.Count(b1.String(), "<path "); got != 1 {
+		t.Errorf("plotGear() wrote %d paths, want 1", got)
+	}
+	if !strings.Contains(b1.String(), " Z\"") {
+		t.Errorf("plotGear() path is not closed")
+	}
+}
+
+func TestMotion(t *testing.T) {
+	var b bytes.Buffer
+	c := &canvas{w: &b, prec: 3}
+	motion{"rotate", []string{"0", "360"}, 0}.plot(c)
+	if b.Len() != 0 {
+		t.Errorf("motion with no duration wrote %q, want nothing", b.String())
+	}
+	motion{"rotate", []string{"0", "-120.000"}, 2.5}.plot(c)
+	want := `<animateTransform attributeName="transform" type="rotate" ` +
+		`additive="sum" values="0;-120.000" dur="2.5s" repeatCount="indefinite" />`
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("motion.plot() == %q, want it to contain %q", b.String(), want)
+	}
+}
+
+func TestStyles(t *testing.T) {
+	c := &canvas{styles: map[string]string{"solid": "stroke:red"}}
+	if got := c.style("solid"); got != "stroke:red" {
+		t.Errorf("style(\"solid\") == %q, want the override", got)
+	}
+	if got := c.style("dash"); got != style("dash") {
+		t.Errorf("style(\"dash\") == %q, want the default", got)
+	}
+	// CSS given by the user cannot break out of the style attribute.
+	css := `stroke:red" onload="alert(1) & <b>`
+	p := gear.NewPair(gear.FromModule(1, 12, 20, 0),
+		gear.FromModule(1, 30, 20, 0))
+	var b bytes.Buffer
+	opts := Options{Annotate: true, Styles: map[string]string{}}
+	for _, name := range Styles {
+		opts.Styles[name] = css
+	}
+	if err := Plot(&b, p, 0, opts); err != nil {
+		t.Fatalf("Plot() returned %v", err)
+	}
+	d := xml.NewDecoder(&b)
+	found := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		if e, ok := tok.(xml.StartElement); ok {
+			for _, a := range e.Attr {
+				if a.Name.Local == "onload" {
+					t.Errorf("Plot() wrote an onload attribute on <%s>",
+						e.Name.Local)
+				}
+				if a.Name.Local == "style" && a.Value == css {
+					found++
+				}
+			}
+		}
+	}
+	if found == 0 {
+		t.Error("Plot() did not write the style given")
+	}
+}
+
+func TestPlotPlanetary(t *testing.T) {
+	p := gear.NewPlanetary(gear.FromModule(1, 12, 20, 0),
+		gear.FromModule(1, 48, 20, 0), 3)
+	opts := Options{Period: 4}
+	var b bytes.Buffer
+	if err := PlotPlanetary(&b, p, gear.RingMember, 0, opts); err != nil {
+		t.Fatalf("PlotPlanetary() returned %v", err)
+	}
+	// One path for each gear, and the ring and sun, each planet and the
+	// carrier turning.
+	if got := strings.Count(b.String(), "<path "); got != 5 {
+		t.Errorf("PlotPlanetary() wrote %d paths, want 5", got)
+	}
+	if got := strings.Count(b.String(), "<animateTransform "); got != 6 {
+		t.Errorf("PlotPlanetary() wrote %d motions, want 6", got)
+	}
+	if !strings.Contains(b.String(), "Ratio: 5.0000") {
+		t.Errorf("PlotPlanetary() did not give the ratio")
+	}
+	p.Planets = 7
+	b.Reset()
+	if err := PlotPlanetary(&b, p, gear.RingMember, 0, opts); err == nil {
+		t.Error("PlotPlanetary() of planets that cannot be spaced evenly " +
+			"returned no error")
+	}
+	if b.Len() != 0 {
+		t.Errorf("PlotPlanetary() wrote %d bytes of a set it cannot draw",
+			b.Len())
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	p := gear.NewPair(gear.FromModule(1, 12, 20, 0),
+		gear.FromModule(1, 30, 20, 0))
+	for _, c := range []struct {
+		prec   int
+		points bool
+	}{{WholeUnits, false}, {2, true}, {0, true}} {
+		var b bytes.Buffer
+		if err := Plot(&b, p, 0, Options{Precision: c.prec}); err != nil {
+			t.Fatalf("Plot() returned %v", err)
 		}
+		s := b.String()
+		i := strings.Index(s, ` d="`)
+		d := s[i+4 : i+4+strings.Index(s[i+4:], `"`)]
+		if strings.Contains(d, ".") != c.points {
+			t.Errorf("Plot() with %d places wrote the path %.40s...", c.prec,
+				d)
+		}
+	}
+}
+
+// A writer that always fails.
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestPlotErrors(t *testing.T) {
+	p := gear.NewPair(gear.FromModule(1, 12, 20, 0), gear.FromModule(1, 30, 20, 0))
+	var b bytes.Buffer
+	if err := Plot(&b, p, 0, Options{}); err != nil {
+		t.Fatalf("Plot() returned %v", err)
+	}
+	if !strings.HasPrefix(b.String(), "<?xml") ||
+		!strings.HasSuffix(b.String(), "</svg>\n") {
+		t.Errorf("Plot() did not write a whole drawing")
+	}
+	if err := Plot(failWriter{}, p, 0, Options{}); err == nil ||
+		err.Error() != "disk full" {
+		t.Errorf("Plot() to a failing writer returned %v", err)
+	}
+	p.G1.N = 0
+	b.Reset()
+	var pe *gear.ParamError
+	if err := Plot(&b, p, 0, Options{}); !errors.As(err, &pe) {
+		t.Errorf("Plot() of a gear with no teeth returned %v", err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("Plot() wrote %d bytes of a pair it cannot draw", b.Len())
+	}
+	rp := gear.RackPair{G: gear.FromModule(1, 12, 20, 0),
+		R: gear.Rack{M: math.NaN(), N: 10, A: 20}}
+	if err := PlotRack(&b, rp, 0, Options{}); !errors.As(err, &pe) {
+		t.Errorf("PlotRack() of a rack with no module returned %v", err)
+	}
+	if err := Cut(&b, nil, Options{}); err == nil {
+		t.Error("Cut() of no contours returned no error")
 	}
 }
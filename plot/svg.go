@@ -0,0 +1,175 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package plot
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/stuphi/GearGen/gear"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A canvas writes SVG elements to w. All dimensions are in mm, written with
+// prec decimal places and any trailing zeros dropped, so the same drawing
+// always gives the same bytes.
+type canvas struct {
+	w      io.Writer
+	prec   int
+	styles map[string]string // CSS for line types in place of the defaults
+}
+
+// A writer that keeps the first error from w and writes nothing more after
+// it, so that a drawing can be written without checking every element.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+// Write p to the underlying writer unless an earlier write failed.
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
+
+// Return the first error writing the drawing, if it was started by start.
+func (c *canvas) err() error {
+	if ew, ok := c.w.(*errWriter); ok {
+		return ew.err
+	}
+	return nil
+}
+
+// Return the style string for the requested line type.
+func (c *canvas) style(s string) string {
+	if css, ok := c.styles[s]; ok {
+		return css
+	}
+	return style(s)
+}
+
+// Return s escaped to be the value of an attribute, as CSS given by the
+// user may hold quotes or other characters special to XML.
+func attr(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Format a dimension for the drawing.
+func (c *canvas) num(f float64) string {
+	s := strconv.FormatFloat(f, 'f', c.prec, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
+
+// Format a point as x,y for a path or a list of values.
+func (c *canvas) point(p gear.Point) string {
+	return c.num(p.X) + "," + c.num(p.Y)
+}
+
+// Start a drawing width by height mm, with one drawing unit to the mm.
+func (c *canvas) start(width, height float64) {
+	fmt.Fprintf(c.w, "<?xml version=\"1.0\"?>\n<svg width=\"%smm\" "+
+		"height=\"%smm\" viewBox=\"0 0 %s %s\" "+
+		"xmlns=\"http://www.w3.org/2000/svg\">\n", c.num(width),
+		c.num(height), c.num(width), c.num(height))
+}
+
+// End the drawing.
+func (c *canvas) end() {
+	fmt.Fprintln(c.w, "</svg>")
+}
+
+// Start a group moved by the transform given.
+func (c *canvas) group(transform string) {
+	fmt.Fprintf(c.w, "<g transform=\"%s\">\n", transform)
+}
+
+// End the group most recently started.
+func (c *canvas) groupEnd() {
+	fmt.Fprintln(c.w, "</g>")
+}
+
+// Draw a line from p1 to p2.
+func (c *canvas) line(p1, p2 gear.Point, style string) {
+	fmt.Fprintf(c.w, "<line x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\" "+
+		"style=\"%s\" />\n", c.num(p1.X), c.num(p1.Y), c.num(p2.X),
+		c.num(p2.Y), attr(style))
+}
+
+// Draw a circle of radius r about centre.
+func (c *canvas) circle(centre gear.Point, r float64, style string) {
+	fmt.Fprintf(c.w, "<circle cx=\"%s\" cy=\"%s\" r=\"%s\" style=\"%s\" />\n",
+		c.num(centre.X), c.num(centre.Y), c.num(r), attr(style))
+}
+
+// Write text s at p.
+func (c *canvas) text(p gear.Point, s string, style string) {
+	fmt.Fprintf(c.w, "<text x=\"%s\" y=\"%s\" style=\"%s\">", c.num(p.X),
+		c.num(p.Y), attr(style))
+	xml.EscapeText(c.w, []byte(s))
+	fmt.Fprintln(c.w, "</text>")
+}
+
+// Draw an outline as a single closed path.
+func (c *canvas) path(o gear.Outline, style string) {
+	fmt.Fprintf(c.w, "<path d=\"%s\" style=\"%s\" />\n", c.pathData(o),
+		attr(style))
+}
+
+// Return the path data for an outline. Lines and polylines become straight
+// runs and arcs keep their true shape.
+func (c *canvas) pathData(o gear.Outline) string {
+	var b strings.Builder
+	b.WriteString("M" + c.point(o[0].Start()))
+	for _, s := range o {
+		if s.Kind == gear.Arc {
+			sweep := 1
+			if s.Clockwise {
+				sweep = 0
+			}
+			large := 0
+			if math.Abs(s.Sweep()) > math.Pi {
+				large = 1
+			}
+			fmt.Fprintf(&b, " A%s,%s 0 %d %d %s", c.num(s.Radius),
+				c.num(s.Radius), large, sweep, c.point(s.End()))
+			continue
+		}
+		b.WriteString(" L")
+		for i, p := range s.Points[1:] {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString(c.point(p))
+		}
+	}
+	b.WriteString(" Z")
+	return b.String()
+}
@@ -0,0 +1,251 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// A package to write our gears as OpenSCAD modules. Each part has its key
+// dimensions as variables, a 2D profile and a 3D solid, so it can be used
+// with other OpenSCAD parts. Include the file to get the variables as well
+// as the modules.
+package scad
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/stuphi/GearGen/gear"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// How far the polygons may stray from the true curves, in mm.
+const Tolerance = 0.01
+
+// The largest angle a helical gear turns through between one slice of an
+// extrusion and the next.
+const twistStep = 1.0
+
+// Format a number for the file, with any trailing zeros dropped.
+func num(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 6, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
+
+// An OpenSCAD file being written to w.
+type file struct {
+	w *bufio.Writer
+}
+
+// Write one line of the file.
+func (f *file) line(format string, a ...interface{}) {
+	fmt.Fprintf(f.w, format+"\n", a...)
+}
+
+// Write a variable with a comment to say what it is.
+func (f *file) variable(name string, v float64, comment string) {
+	f.line("%s = %s; // %s", name, num(v), comment)
+}
+
+// Write a module that draws the outlines as one polygon. Where outlines
+// overlap, the inner one is a hole.
+func (f *file) polygon(name string, outlines ...gear.Outline) {
+	f.line("module %s() {", name)
+	fmt.Fprint(f.w, "  polygon(points = [")
+	var paths [][]int
+	n := 0
+	for _, o := range outlines {
+		var path []int
+		for _, p := range o.Polygon(Tolerance) {
+			if n > 0 {
+				fmt.Fprint(f.w, ",")
+			}
+			if n%4 == 0 {
+				fmt.Fprint(f.w, "\n    ")
+			} else {
+				fmt.Fprint(f.w, " ")
+			}
+			fmt.Fprintf(f.w, "[%s, %s]", num(p.X), num(p.Y))
+			path = append(path, n)
+			n++
+		}
+		paths = append(paths, path)
+	}
+	fmt.Fprint(f.w, "],\n    paths = [")
+	for i, path := range paths {
+		if i > 0 {
+			fmt.Fprint(f.w, ", ")
+		}
+		s := make([]string, len(path))
+		for j, k := range path {
+			s[j] = strconv.Itoa(k)
+		}
+		fmt.Fprintf(f.w, "[%s]", strings.Join(s, ", "))
+	}
+	f.line("]);")
+	f.line("}")
+	f.line("")
+}
+
+// Write the variables and modules for gear g, each named after the part.
+// name_profile() is the face of the gear and name() is the solid, both
+// centred on the origin with the first tooth on the positive x axis.
+func (f *file) gear(name string, g gear.Gear) {
+	// OpenSCAD twists clockwise, where a right hand helix turns anticlockwise
+	// up the axis.
+	twist := -g.GetTwist() * gear.RadToDeg
+	slices := int(math.Max(1, math.Ceil(math.Abs(twist)/twistStep)))
+	f.line("// %s", name)
+	f.variable(name+"_teeth", float64(g.N), "number of teeth")
+	f.variable(name+"_module", g.GetModule(), "module")
+	f.variable(name+"_pressure_angle", g.A, "pressure angle (degrees)")
+	if g.Helix != 0 {
+		f.variable(name+"_normal_module", g.GetNormalModule(), "normal module")
+		f.variable(name+"_helix", g.Helix, fmt.Sprintf("helix angle "+
+			"(degrees), %s hand", g.Hand))
+	}
+	f.variable(name+"_pitch_dia", g.Pd, "pitch diameter")
+	f.variable(name+"_tip_dia", g.GetTipDia(), "tip diameter")
+	f.variable(name+"_root_dia", g.GetRootCircleDia(), "root diameter")
+	f.variable(name+"_base_dia", g.GetBaseCircleDia(), "base diameter")
+	if g.Internal {
+		f.variable(name+"_rim_dia", g.GetRimDia(), "rim diameter")
+	} else if g.Bore.Dia > 0 {
+		f.variable(name+"_bore", g.Bore.Dia, "bore diameter")
+	}
+	f.variable(name+"_face", g.Face, "face width")
+	f.variable(name+"_twist", twist, "twist over the face (degrees)")
+	f.line("")
+	// Only the teeth twist.
+	teeth, rest := g.SplitContours()
+	if g.Internal {
+		f.polygon(name+"_rim", rest...)
+		f.polygon(name+"_outline", teeth...)
+		f.line("module %s_profile() {", name)
+		f.line("  difference() {\n    %s_rim();\n    %s_outline();\n  }", name,
+			name)
+		f.line("}\n")
+		// The teeth are cut right through, so start them below the rim
+		// and turn them to match.
+		f.line("module %s() {", name)
+		f.line("  difference() {")
+		f.line("    linear_extrude(height = %s_face) %s_rim();", name, name)
+		f.line("    translate([0, 0, -1]) rotate(%s_twist / %s_face)", name,
+			name)
+		f.line("      linear_extrude(height = %s_face + 2,", name)
+		f.line("        twist = %s_twist * (%s_face + 2) / %s_face, "+
+			"slices = %d)", name, name, name, slices)
+		f.line("        %s_outline();", name)
+		f.line("  }")
+		f.line("}\n")
+		return
+	}
+	holes := rest
+	f.polygon(name+"_outline", teeth...)
+	if len(holes) == 0 {
+		f.line("module %s_profile() {\n  %s_outline();\n}\n", name, name)
+		f.line("module %s() {", name)
+		f.line("  linear_extrude(height = %s_face, twist = %s_twist, "+
+			"slices = %d)", name, name, slices)
+		f.line("    %s_outline();", name)
+		f.line("}\n")
+		return
+	}
+	f.polygon(name+"_holes", holes...)
+	f.line("module %s_profile() {", name)
+	f.line("  difference() {\n    %s_outline();\n    %s_holes();\n  }", name,
+		name)
+	f.line("}\n")
+	f.line("module %s() {", name)
+	f.line("  difference() {")
+	f.line("    linear_extrude(height = %s_face, twist = %s_twist, "+
+		"slices = %d)", name, name, slices)
+	f.line("      %s_outline();", name)
+	f.line("    translate([0, 0, -1]) linear_extrude(height = %s_face + 2)",
+		name)
+	f.line("      %s_holes();", name)
+	f.line("  }")
+	f.line("}\n")
+}
+
+// Write the variables and modules for rack r, face mm thick. The pitch line
+// of the rack runs along the x axis, centred on the origin.
+func (f *file) rack(name string, r gear.Rack, face float64) {
+	f.line("// %s", name)
+	f.variable(name+"_teeth", float64(r.N), "number of teeth")
+	f.variable(name+"_module", r.M, "module")
+	f.variable(name+"_pressure_angle", r.A, "pressure angle (degrees)")
+	f.variable(name+"_length", r.GetLength(), "overall length")
+	f.variable(name+"_face", face, "face width")
+	f.line("")
+	f.polygon(name+"_profile", r.Contours()...)
+	f.line("module %s() {", name)
+	f.line("  linear_extrude(height = %s_face) %s_profile();", name, name)
+	f.line("}\n")
+}
+
+// Write the heading of the file.
+func (f *file) heading() {
+	f.line("// Generated by GearGen. All dimensions are in mm.")
+	f.line("")
+}
+
+// Write the pair of gears p to w. The modules gear1() and gear2() are the
+// gears on their own, and pair() shows them in mesh with the first gear on
+// the origin and rotfrac the percentage of one tooth to rotate both, as for
+// plot.Plot.
+func Plot(w io.Writer, p gear.Pair, rotfrac int) error {
+	f := file{bufio.NewWriter(w)}
+	f.heading()
+	f.variable("centres", p.C, "centre distance")
+	f.variable("ratio", float64(p.G2.N)/float64(p.G1.N), "ratio")
+	f.line("")
+	f.gear("gear1", p.G1)
+	f.gear("gear2", p.G2)
+	rot1, rot2 := p.GetRotations(float64(rotfrac))
+	f.line("module pair() {")
+	f.line("  rotate(%s) gear1();", num(rot1))
+	// An internal gear sits the other side of the pinion.
+	c := "centres"
+	if p.G2.Internal {
+		c = "-centres"
+	}
+	f.line("  translate([%s, 0, 0]) rotate(%s) gear2();", c, num(rot2))
+	f.line("}")
+	return f.w.Flush()
+}
+
+// Write the rack and pinion p to w, with the rack face mm thick. The
+// modules pinion() and rack() are the parts on their own, and pair() shows
+// them in mesh with rotfrac the percentage of one tooth to turn the pinion,
+// as for plot.PlotRack.
+func PlotRack(w io.Writer, p gear.RackPair, rotfrac int, face float64) error {
+	f := file{bufio.NewWriter(w)}
+	f.heading()
+	f.variable("centre_height", p.GetCentreHeight(), "height of the pinion "+
+		"above the pitch line")
+	f.line("")
+	f.gear("pinion", p.G)
+	f.rack("rack", p.R, face)
+	rot, travel := p.GetRotation(float64(rotfrac))
+	f.line("module pair() {")
+	f.line("  translate([0, centre_height, 0]) rotate(%s) pinion();", num(rot))
+	f.line("  translate([%s, 0, 0]) rack();", num(travel))
+	f.line("}")
+	return f.w.Flush()
+}
@@ -0,0 +1,136 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package scad
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/stuphi/GearGen/gear"
+	"strings"
+	"testing"
+)
+
+func TestNum(t *testing.T) {
+	for _, c := range []struct {
+		f    float64
+		want string
+	}{
+		{1, "1"},
+		{-0.0000001, "0"},
+		{2.5, "2.5"},
+		{1.23456789, "1.234568"},
+	} {
+		if got := num(c.f); got != c.want {
+			t.Errorf("num(%g) == %q, want %q", c.f, got, c.want)
+		}
+	}
+}
+
+func TestPolygon(t *testing.T) {
+	square := gear.Outline{
+		{Kind: gear.Line, Points: []gear.Point{{X: 0, Y: 0}, {X: 2, Y: 0},
+			{X: 2, Y: 2}, {X: 0, Y: 2}, {X: 0, Y: 0}}},
+	}
+	var b bytes.Buffer
+	f := file{bufio.NewWriter(&b)}
+	f.polygon("sq", square, square.Translate(3, 0))
+	f.w.Flush()
+	want := "module sq() {\n  polygon(points = [\n" +
+		"    [2, 0], [2, 2], [0, 2], [0, 0],\n" +
+		"    [5, 0], [5, 2], [3, 2], [3, 0]],\n" +
+		"    paths = [[0, 1, 2, 3], [4, 5, 6, 7]]);\n}\n"
+	if !strings.HasPrefix(b.String(), want) {
+		t.Errorf("polygon() wrote %q, want %q", b.String(), want)
+	}
+}
+
+func TestPlot(t *testing.T) {
+	g1 := gear.FromNormal(2, 17, 20, 0.5, 20)
+	g2 := gear.FromNormal(2, 41, 20, 0.5, 20)
+	g1.Face, g2.Face = 10, 10
+	g2.Hand = gear.LeftHand
+	g1.Bore = gear.Bore{Dia: 8}
+	p := gear.Pair{G1: g1, G2: g2, C: gear.WorkingCentreDistance(g1, g2)}
+	var b bytes.Buffer
+	if err := Plot(&b, p, 0); err != nil {
+		t.Fatalf("Plot() returned %v", err)
+	}
+	s := b.String()
+	for _, want := range []string{
+		"gear1_teeth = 17;",
+		"gear2_helix = 20;",
+		"module gear1_holes() {",
+		"module gear1() {",
+		"module gear2_profile() {",
+		"translate([centres, 0, 0]) rotate(",
+		"linear_extrude(height = gear1_face, twist = gear1_twist, slices = 12)",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Plot() wrote no %q", want)
+		}
+	}
+	// A right hand helix turns anticlockwise, which OpenSCAD calls a
+	// negative twist.
+	if !strings.Contains(s, "gear1_twist = -") ||
+		strings.Contains(s, "gear2_twist = -") {
+		t.Errorf("Plot() gave the wrong twist for a right and left hand pair")
+	}
+	if strings.Count(s, "{") != strings.Count(s, "}") {
+		t.Errorf("Plot() wrote unbalanced braces")
+	}
+}
+
+func TestPlotRack(t *testing.T) {
+	g := gear.FromModule(2, 15, 20, 0.5)
+	g.Face = 8
+	r := gear.RackFor(g, 6)
+	var b bytes.Buffer
+	if err := PlotRack(&b, gear.RackPair{G: g, R: r}, 0, 8); err != nil {
+		t.Fatalf("PlotRack() returned %v", err)
+	}
+	s := b.String()
+	for _, want := range []string{
+		"centre_height = 15;",
+		"module pinion() {",
+		"module rack_profile() {",
+		"linear_extrude(height = rack_face) rack_profile();",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("PlotRack() wrote no %q", want)
+		}
+	}
+}
+
+func TestInternal(t *testing.T) {
+	g1 := gear.FromModule(2, 15, 20, 0.5)
+	g2 := gear.FromModule(2, 40, 20, 0.5)
+	g2.Internal = true
+	g1.Face, g2.Face = 10, 10
+	p := gear.Pair{G1: g1, G2: g2, C: gear.WorkingCentreDistance(g1, g2)}
+	var b bytes.Buffer
+	Plot(&b, p, 0)
+	s := b.String()
+	for _, want := range []string{
+		"gear2_rim_dia = ",
+		"module gear2_rim() {",
+		"translate([-centres, 0, 0])",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Plot() of an internal gear wrote no %q", want)
+		}
+	}
+}
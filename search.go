@@ -0,0 +1,75 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stuphi/GearGen/gear"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Return the modules in the comma separated list s.
+func parseModules(s string) ([]float64, error) {
+	var retval []float64
+	for _, f := range strings.Split(s, ",") {
+		m, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, fmt.Errorf("module %q is not a number", f)
+		}
+		retval = append(retval, m)
+	}
+	return retval, nil
+}
+
+// Write the solutions of a search to w, ranked best first, as a table or as
+// JSON.
+func writeSolutions(w io.Writer, sols []gear.Solution, asJSON bool) error {
+	if asJSON {
+		if sols == nil {
+			sols = []gear.Solution{}
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(sols)
+	}
+	if len(sols) == 0 {
+		_, err := fmt.Fprintln(w, "No gears found within the limits")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Rank\tRatio\tError %\tHunting\tContact\tSize\tStages "+
+		"(driver:driven module centres)")
+	for i, sol := range sols {
+		var stages []string
+		for _, st := range sol.Stages {
+			stages = append(stages, fmt.Sprintf("%d:%d m%g %.3f", st.Driver,
+				st.Driven, st.Module, st.Centres))
+		}
+		hunting := "no"
+		if sol.Hunting {
+			hunting = "yes"
+		}
+		fmt.Fprintf(tw, "%d\t%.4f\t%.4f\t%s\t%.3f\t%.1f\t%s\n", i+1, sol.Ratio,
+			100*sol.Error, hunting, sol.ContactRatio, sol.Size,
+			strings.Join(stages, ", "))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,53 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stuphi/GearGen/gear"
+	"strings"
+	"testing"
+)
+
+func TestWriteSolutions(t *testing.T) {
+	sols := []gear.Solution{{Stages: []gear.Stage{{Module: 1, Driver: 13,
+		Driven: 40, Centres: 26.5, ContactRatio: 1.5}}, Ratio: 40.0 / 13,
+		Error: 0.0026, Hunting: true, ContactRatio: 1.5, Size: 53}}
+	var b bytes.Buffer
+	if err := writeSolutions(&b, sols, false); err != nil {
+		t.Fatalf("writeSolutions() returned %v", err)
+	}
+	if !strings.Contains(b.String(), "13:40 m1 26.500") ||
+		!strings.Contains(b.String(), "yes") {
+		t.Errorf("writeSolutions() wrote %q", b.String())
+	}
+	b.Reset()
+	if err := writeSolutions(&b, sols, true); err != nil {
+		t.Fatalf("writeSolutions() returned %v", err)
+	}
+	var got []gear.Solution
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("writeSolutions() wrote bad JSON: %v", err)
+	}
+	if len(got) != 1 || got[0].Stages[0] != sols[0].Stages[0] {
+		t.Errorf("writeSolutions() wrote %+v, want %+v", got, sols)
+	}
+	if _, err := parseModules("1, 1.5,2"); err != nil {
+		t.Errorf("parseModules() returned %v", err)
+	}
+}
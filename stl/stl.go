@@ -0,0 +1,270 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// A package to extrude our gears into solids and write them as STL meshes
+// for 3D printing.
+package stl
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/stuphi/GearGen/gear"
+	"io"
+	"math"
+)
+
+// How far the facets may stray from the true curves, in mm.
+const Tolerance = 0.01
+
+// The largest angle a helical gear turns through between one ring of
+// facets and the next up its side.
+const twistStep = 1 * gear.DegToRad
+
+// A point or direction in space, in mm.
+type Vector struct {
+	X, Y, Z float64
+}
+
+// Return the vector from v to u.
+func (v Vector) to(u Vector) Vector {
+	return Vector{u.X - v.X, u.Y - v.Y, u.Z - v.Z}
+}
+
+// Return the cross product of v and u.
+func (v Vector) cross(u Vector) Vector {
+	return Vector{v.Y*u.Z - v.Z*u.Y, v.Z*u.X - v.X*u.Z, v.X*u.Y - v.Y*u.X}
+}
+
+// One facet of a mesh. The corners run anticlockwise seen from outside the
+// solid.
+type Triangle [3]Vector
+
+// Return the unit normal of the facet, pointing out of the solid.
+func (t Triangle) Normal() Vector {
+	n := t[0].to(t[1]).cross(t[0].to(t[2]))
+	l := math.Sqrt(n.X*n.X + n.Y*n.Y + n.Z*n.Z)
+	if l == 0 {
+		return Vector{}
+	}
+	return Vector{n.X / l, n.Y / l, n.Z / l}
+}
+
+// A closed surface made up of triangular facets.
+type Mesh []Triangle
+
+// Return the mesh moved by dx, dy and dz.
+func (m Mesh) Translate(dx, dy, dz float64) Mesh {
+	retval := make(Mesh, len(m))
+	for i, t := range m {
+		for j, v := range t {
+			retval[i][j] = Vector{v.X + dx, v.Y + dy, v.Z + dz}
+		}
+	}
+	return retval
+}
+
+// Return the corners of the box that bounds the mesh.
+func (m Mesh) Bounds() (Vector, Vector) {
+	min := Vector{math.Inf(1), math.Inf(1), math.Inf(1)}
+	max := Vector{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, t := range m {
+		for _, v := range t {
+			min = Vector{math.Min(min.X, v.X), math.Min(min.Y, v.Y),
+				math.Min(min.Z, v.Z)}
+			max = Vector{math.Max(max.X, v.X), math.Max(max.Y, v.Y),
+				math.Max(max.Z, v.Z)}
+		}
+	}
+	return min, max
+}
+
+// Calculate and return the volume enclosed by the mesh in mm³.
+func (m Mesh) Volume() float64 {
+	var v float64
+	for _, t := range m {
+		v += t[0].X*(t[1].Y*t[2].Z-t[1].Z*t[2].Y) +
+			t[0].Y*(t[1].Z*t[2].X-t[1].X*t[2].Z) +
+			t[0].Z*(t[1].X*t[2].Y-t[1].Y*t[2].X)
+	}
+	return v / 6
+}
+
+// A closed contour of a part to extrude, as returned by gear.Contours, and
+// the angle in radians that it turns through from the bottom face to the
+// top.
+type Contour struct {
+	Outline gear.Outline
+	Twist   float64
+}
+
+// Extrude the contours of a part face mm up the z axis, from z = 0. There
+// must be one contour running anticlockwise round the outside, and any
+// others are holes inside it running clockwise. Contours that twist are
+// built up from rings of facets, each turned a little from the last.
+func Extrude(cs []Contour, face float64) (Mesh, error) {
+	steps := 1
+	for _, c := range cs {
+		if n := int(math.Ceil(math.Abs(c.Twist) / twistStep)); n > steps {
+			steps = n
+		}
+	}
+	// Flatten each contour once and turn the points, so the rings of every
+	// layer match.
+	polys := make([][]gear.Point, len(cs))
+	for i, c := range cs {
+		polys[i] = clean(c.Outline.Polygon(Tolerance))
+	}
+	layer := func(k int) [][]gear.Point {
+		retval := make([][]gear.Point, len(cs))
+		for i, c := range cs {
+			rot := c.Twist * float64(k) / float64(steps)
+			retval[i] = make([]gear.Point, len(polys[i]))
+			for j, p := range polys[i] {
+				retval[i][j] = p.Rotate(rot)
+			}
+		}
+		return retval
+	}
+	var m Mesh
+	bottom, err := capFaces(layer(0))
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range bottom {
+		m = append(m, Triangle{
+			{t[0].X, t[0].Y, 0}, {t[2].X, t[2].Y, 0}, {t[1].X, t[1].Y, 0}})
+	}
+	top, err := capFaces(layer(steps))
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range top {
+		m = append(m, Triangle{{t[0].X, t[0].Y, face},
+			{t[1].X, t[1].Y, face}, {t[2].X, t[2].Y, face}})
+	}
+	lower := layer(0)
+	for k := 1; k <= steps; k++ {
+		upper := layer(k)
+		z0 := face * float64(k-1) / float64(steps)
+		z1 := face * float64(k) / float64(steps)
+		for i := range lower {
+			n := len(lower[i])
+			for j := range lower[i] {
+				p0 := Vector{lower[i][j].X, lower[i][j].Y, z0}
+				q0 := Vector{lower[i][(j+1)%n].X, lower[i][(j+1)%n].Y, z0}
+				p1 := Vector{upper[i][j].X, upper[i][j].Y, z1}
+				q1 := Vector{upper[i][(j+1)%n].X, upper[i][(j+1)%n].Y, z1}
+				m = append(m, Triangle{p0, q0, q1}, Triangle{p0, q1, p1})
+			}
+		}
+		lower = upper
+	}
+	return m, nil
+}
+
+// Cut one face of a part into triangles. The outside is the polygon that
+// runs anticlockwise.
+func capFaces(polys [][]gear.Point) ([][3]gear.Point, error) {
+	var outer []gear.Point
+	var holes [][]gear.Point
+	for _, p := range polys {
+		if area(p) > 0 {
+			if outer != nil {
+				return nil, fmt.Errorf("a part has more than one outside")
+			}
+			outer = p
+		} else {
+			holes = append(holes, p)
+		}
+	}
+	return triangulate(outer, holes)
+}
+
+// Return the solid of gear g, extruded to its face width. The teeth of a
+// helical gear twist along the axis while the bore, any lightening holes
+// and the rim of an internal gear run straight.
+func Gear(g gear.Gear) (Mesh, error) {
+	teeth, rest := g.SplitContours()
+	var parts []Contour
+	for _, o := range teeth {
+		parts = append(parts, Contour{Outline: o, Twist: g.GetTwist()})
+	}
+	for _, o := range rest {
+		parts = append(parts, Contour{Outline: o})
+	}
+	return Extrude(parts, g.Face)
+}
+
+// Return the solid of rack r, extruded face mm thick.
+func Rack(r gear.Rack, face float64) (Mesh, error) {
+	var parts []Contour
+	for _, o := range r.Contours() {
+		parts = append(parts, Contour{Outline: o})
+	}
+	return Extrude(parts, face)
+}
+
+// Lay out solids from left to right along the x axis with gap mm between
+// them, each centred on the x axis, as gear.Layout does for contours.
+func Layout(gap float64, ms ...Mesh) Mesh {
+	var retval Mesh
+	x := 0.0
+	for _, m := range ms {
+		if len(m) == 0 {
+			continue
+		}
+		min, max := m.Bounds()
+		retval = append(retval, m.Translate(x-min.X, -(min.Y+max.Y)/2, 0)...)
+		x += max.X - min.X + gap
+	}
+	return retval
+}
+
+// Write the mesh to w as a binary STL file.
+func (m Mesh) WriteBinary(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	var header [80]byte
+	copy(header[:], "GearGen")
+	b.Write(header[:])
+	binary.Write(b, binary.LittleEndian, uint32(len(m)))
+	for _, t := range m {
+		n := t.Normal()
+		vs := []float32{float32(n.X), float32(n.Y), float32(n.Z)}
+		for _, v := range t {
+			vs = append(vs, float32(v.X), float32(v.Y), float32(v.Z))
+		}
+		binary.Write(b, binary.LittleEndian, vs)
+		binary.Write(b, binary.LittleEndian, uint16(0))
+	}
+	return b.Flush()
+}
+
+// Write the mesh to w as an ASCII STL file for a solid with the given name.
+func (m Mesh) WriteASCII(w io.Writer, name string) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "solid %s\n", name)
+	for _, t := range m {
+		n := t.Normal()
+		fmt.Fprintf(b, "facet normal %e %e %e\n outer loop\n", n.X, n.Y, n.Z)
+		for _, v := range t {
+			fmt.Fprintf(b, "  vertex %e %e %e\n", v.X, v.Y, v.Z)
+		}
+		fmt.Fprintln(b, " endloop\nendfacet")
+	}
+	fmt.Fprintf(b, "endsolid %s\n", name)
+	return b.Flush()
+}
@@ -0,0 +1,218 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package stl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stuphi/GearGen/gear"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Check that every edge of the mesh is shared by exactly two facets that
+// run along it in opposite directions, so the surface is closed and all the
+// normals point the same way.
+func checkClosed(t *testing.T, name string, m Mesh) {
+	type edge struct{ a, b Vector }
+	count := map[edge]int{}
+	for _, tr := range m {
+		for i := range tr {
+			count[edge{tr[i], tr[(i+1)%3]}]++
+		}
+	}
+	for e, n := range count {
+		if n != 1 || count[edge{e.b, e.a}] != 1 {
+			t.Errorf("%s: edge %v to %v is used %d times and reversed %d "+
+				"times, want 1 and 1", name, e.a, e.b, n, count[edge{e.b, e.a}])
+			return
+		}
+	}
+}
+
+// Return the area of the face of a part, from its contours.
+func faceArea(cs []gear.Outline) float64 {
+	var a float64
+	for _, o := range cs {
+		a += area(o.Polygon(Tolerance)) / 2
+	}
+	return a
+}
+
+func TestTriangulate(t *testing.T) {
+	// A square with a square hole, which leaves an area of 12.
+	outer := []gear.Point{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 4}, {X: 0, Y: 4}}
+	hole := []gear.Point{{X: 1, Y: 1}, {X: 1, Y: 3}, {X: 3, Y: 3}, {X: 3, Y: 1}}
+	ts, err := triangulate(outer, [][]gear.Point{hole})
+	if err != nil {
+		t.Fatalf("triangulate() returned %v", err)
+	}
+	var a float64
+	for _, tr := range ts {
+		c := cross(tr[0], tr[1], tr[2])
+		if c <= 0 {
+			t.Errorf("triangle %v does not run anticlockwise", tr)
+		}
+		a += c / 2
+	}
+	if math.Abs(a-12) > 1e-9 {
+		t.Errorf("triangles cover %.6f, want 12", a)
+	}
+	if len(ts) != 8 {
+		t.Errorf("triangulate() gave %d triangles, want 8", len(ts))
+	}
+	if _, err := triangulate(hole, nil); err == nil {
+		t.Error("triangulate() of a clockwise outside returned no error")
+	}
+}
+
+func TestClean(t *testing.T) {
+	pts := []gear.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 0},
+		{X: 2, Y: 2}, {X: 0, Y: 2}, {X: 0, Y: 0}}
+	if got := clean(pts); len(got) != 4 {
+		t.Errorf("clean() == %v, want the 4 corners", got)
+	}
+}
+
+func TestGear(t *testing.T) {
+	g := gear.FromModule(2, 30, 20, 0.5)
+	g.Rf = 0.38
+	g.Face = 8
+	g.Bore = gear.Bore{Dia: 12, Kind: gear.DIN6885Bore}
+	g.Web = gear.Web{Kind: gear.HoleWeb, N: 5}
+	ri := gear.FromModule(2, 50, 20, 0.5)
+	ri.Internal = true
+	ri.Face = 8
+	h := gear.FromNormal(2, 20, 20, 0.5, 20)
+	h.Face = 10
+	h.Bore = gear.Bore{Dia: 8}
+	// The twisted sides of a helical gear are made of flat facets, which cut
+	// a little off the true volume.
+	for _, c := range []struct {
+		name string
+		g    gear.Gear
+		tol  float64
+	}{
+		{"external", g, 1e-6},
+		{"internal", ri, 1e-6},
+		{"helical", h, 1e-3},
+	} {
+		m, err := Gear(c.g)
+		if err != nil {
+			t.Errorf("Gear(%s) returned %v", c.name, err)
+			continue
+		}
+		checkClosed(t, c.name, m)
+		want := faceArea(c.g.Contours()) * c.g.Face
+		if got := m.Volume(); math.Abs(got-want) > c.tol*want {
+			t.Errorf("Gear(%s).Volume() == %.3f, want %.3f", c.name, got, want)
+		}
+		lo, hi := m.Bounds()
+		if lo.Z != 0 || hi.Z != c.g.Face {
+			t.Errorf("Gear(%s) runs from z = %.3f to %.3f, want 0 to %.3f",
+				c.name, lo.Z, hi.Z, c.g.Face)
+		}
+	}
+}
+
+func TestHelicalTwist(t *testing.T) {
+	g := gear.FromNormal(2, 20, 20, 0, 20)
+	g.Face = 10
+	m, err := Gear(g)
+	if err != nil {
+		t.Fatalf("Gear() returned %v", err)
+	}
+	// The top face should match the section of the gear at the top.
+	tip := g.SectionAt(g.Face)[0].Start()
+	found := false
+	for _, tr := range m {
+		for _, v := range tr {
+			if v.Z == g.Face && math.Hypot(v.X-tip.X, v.Y-tip.Y) < 1e-9 {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no vertex on the top face at %v", tip)
+	}
+}
+
+func TestRack(t *testing.T) {
+	r := gear.Rack{M: 2, N: 6, A: 20}
+	m, err := Rack(r, 5)
+	if err != nil {
+		t.Fatalf("Rack() returned %v", err)
+	}
+	checkClosed(t, "rack", m)
+	want := faceArea(r.Contours()) * 5
+	if got := m.Volume(); math.Abs(got-want) > 1e-6*want {
+		t.Errorf("Rack().Volume() == %.3f, want %.3f", got, want)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	g := gear.FromModule(2, 20, 20, 0)
+	g.Face = 5
+	m, _ := Gear(g)
+	l := Layout(5, m, m)
+	lo, hi := l.Bounds()
+	if math.Abs(lo.X) > 1e-9 || math.Abs(hi.X-2*g.GetTipDia()-5) > 1e-3 {
+		t.Errorf("Layout() spans x = %.3f to %.3f, want 0 to %.3f", lo.X, hi.X,
+			2*g.GetTipDia()+5)
+	}
+	if math.Abs(lo.Y+hi.Y) > 1e-9 {
+		t.Errorf("Layout() spans y = %.3f to %.3f, want it centred", lo.Y, hi.Y)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	m := Mesh{
+		{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+		{{0, 0, 1}, {0, 1, 1}, {1, 0, 1}},
+	}
+	var b bytes.Buffer
+	if err := m.WriteBinary(&b); err != nil {
+		t.Fatalf("WriteBinary() returned %v", err)
+	}
+	if b.Len() != 84+50*len(m) {
+		t.Errorf("WriteBinary() wrote %d bytes, want %d", b.Len(), 84+50*len(m))
+	}
+	if n := binary.LittleEndian.Uint32(b.Bytes()[80:]); n != 2 {
+		t.Errorf("WriteBinary() gave a count of %d facets, want 2", n)
+	}
+	var nz float32
+	binary.Read(bytes.NewReader(b.Bytes()[92:]), binary.LittleEndian, &nz)
+	if nz != 1 {
+		t.Errorf("WriteBinary() gave the first normal a z of %g, want 1", nz)
+	}
+	b.Reset()
+	if err := m.WriteASCII(&b, "test"); err != nil {
+		t.Fatalf("WriteASCII() returned %v", err)
+	}
+	s := b.String()
+	if !strings.HasPrefix(s, "solid test\n") ||
+		!strings.HasSuffix(s, "endsolid test\n") {
+		t.Errorf("WriteASCII() wrote %q, want a solid named test", s)
+	}
+	if got := strings.Count(s, "facet normal"); got != 2 {
+		t.Errorf("WriteASCII() wrote %d facets, want 2", got)
+	}
+	if !strings.Contains(s, "facet normal 0.000000e+00 0.000000e+00 -1.000000e+00") {
+		t.Errorf("WriteASCII() wrote %q, want a facet facing down", s)
+	}
+}
@@ -0,0 +1,276 @@
+// GearGen -- Simple utility to generate gear profiles in SVG format
+// Copyright (C) 2015  Philip Stubbs
+//
+// GearGen is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// GearGen is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package stl
+
+import (
+	"fmt"
+	"github.com/stuphi/GearGen/gear"
+	"math"
+	"sort"
+)
+
+// Return twice the signed area of the triangle a, b, c. It is positive if
+// the triangle runs anticlockwise.
+func cross(a, b, c gear.Point) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+// Return twice the signed area of a polygon.
+func area(pts []gear.Point) float64 {
+	var a float64
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		a += p.X*q.Y - q.X*p.Y
+	}
+	return a
+}
+
+// Return the polygon with repeated points and points in a straight line
+// with their neighbours taken out.
+func clean(pts []gear.Point) []gear.Point {
+	var retval []gear.Point
+	for _, p := range pts {
+		if len(retval) > 0 && p == retval[len(retval)-1] {
+			continue
+		}
+		retval = append(retval, p)
+	}
+	for len(retval) > 1 && retval[0] == retval[len(retval)-1] {
+		retval = retval[:len(retval)-1]
+	}
+	for changed := true; changed && len(retval) > 2; {
+		changed = false
+		for i := 0; i < len(retval) && len(retval) > 2; i++ {
+			n := len(retval)
+			a, b, c := retval[(i+n-1)%n], retval[i], retval[(i+1)%n]
+			if collinear(a, b, c) {
+				retval = append(retval[:i], retval[i+1:]...)
+				changed = true
+				i--
+			}
+		}
+	}
+	return retval
+}
+
+// Report whether b lies on the straight line through a and c, to within a
+// tolerance scaled to the size of the triangle.
+func collinear(a, b, c gear.Point) bool {
+	l := math.Max(math.Hypot(b.X-a.X, b.Y-a.Y), math.Hypot(c.X-b.X, c.Y-b.Y))
+	return math.Abs(cross(a, b, c)) <= 1e-12*l*l
+}
+
+// Report whether the segments p1-p2 and q1-q2 cross at a point inside both
+// of them.
+func crosses(p1, p2, q1, q2 gear.Point) bool {
+	d1, d2 := cross(p1, p2, q1), cross(p1, p2, q2)
+	d3, d4 := cross(q1, q2, p1), cross(q1, q2, p2)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+// Report whether p lies on the segment a-b, short of its ends.
+func onSegment(p, a, b gear.Point) bool {
+	if p == a || p == b || !collinear(a, p, b) {
+		return false
+	}
+	return (p.X-a.X)*(p.X-b.X)+(p.Y-a.Y)*(p.Y-b.Y) < 0
+}
+
+// Report whether the segment from the vertex v of an anticlockwise ring to p
+// leaves v on the inside of the ring, between the edges from prev and to
+// next.
+func inWedge(prev, v, next, p gear.Point) bool {
+	if cross(prev, v, next) > 0 {
+		return cross(prev, v, p) > 0 && cross(v, next, p) > 0
+	}
+	return cross(prev, v, p) > 0 || cross(v, next, p) > 0
+}
+
+// Join the holes to the outside polygon, giving a single ring that runs
+// anticlockwise. Each hole is joined by a bridge from its rightmost point to
+// the nearest point it can see, and the ring runs out along the bridge,
+// round the hole and back.
+func bridge(outer []gear.Point, holes [][]gear.Point) ([]gear.Point, error) {
+	sort.Slice(holes, func(i, j int) bool {
+		return maxX(holes[i]) > maxX(holes[j])
+	})
+	ring := outer
+	for h, hole := range holes {
+		m := 0
+		for i, p := range hole {
+			if p.X > hole[m].X {
+				m = i
+			}
+		}
+		mp := hole[m]
+		// Try the points of the ring nearest first.
+		order := make([]int, len(ring))
+		for i := range order {
+			order[i] = i
+		}
+		dist := func(i int) float64 {
+			return math.Hypot(ring[i].X-mp.X, ring[i].Y-mp.Y)
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return dist(order[i]) < dist(order[j])
+		})
+		v := -1
+		for _, i := range order {
+			n := len(ring)
+			if !inWedge(ring[(i+n-1)%n], ring[i], ring[(i+1)%n], mp) {
+				continue
+			}
+			if visible(ring[i], mp, ring) && visible(ring[i], mp, hole) &&
+				visibleAll(ring[i], mp, holes[h+1:]) {
+				v = i
+				break
+			}
+		}
+		if v < 0 {
+			return nil, fmt.Errorf("cannot join a hole to the outside")
+		}
+		joined := append([]gear.Point{}, ring[:v+1]...)
+		for i := 0; i <= len(hole); i++ {
+			joined = append(joined, hole[(m+i)%len(hole)])
+		}
+		joined = append(joined, ring[v])
+		ring = append(joined, ring[v+1:]...)
+	}
+	return ring, nil
+}
+
+// Return the largest x of any point of the polygon.
+func maxX(pts []gear.Point) float64 {
+	x := math.Inf(-1)
+	for _, p := range pts {
+		x = math.Max(x, p.X)
+	}
+	return x
+}
+
+// Report whether the segment a-b is clear of the edges and points of the
+// polygon pts, other than where it meets them at a or b.
+func visible(a, b gear.Point, pts []gear.Point) bool {
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		if crosses(a, b, p, q) || onSegment(p, a, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// Report whether the segment a-b is clear of all the polygons.
+func visibleAll(a, b gear.Point, polys [][]gear.Point) bool {
+	for _, pts := range polys {
+		if !visible(a, b, pts) {
+			return false
+		}
+	}
+	return true
+}
+
+// Cut a polygon with holes into triangles, each running anticlockwise. The
+// outside must run anticlockwise and the holes clockwise, all within it, and
+// all of them cleaned.
+func triangulate(outer []gear.Point, holes [][]gear.Point) ([][3]gear.Point,
+	error) {
+	if len(outer) < 3 || area(outer) <= 0 {
+		return nil, fmt.Errorf("the outside of a part must run anticlockwise")
+	}
+	var hs [][]gear.Point
+	for _, h := range holes {
+		if len(h) < 3 {
+			continue
+		}
+		if area(h) >= 0 {
+			return nil, fmt.Errorf("the holes in a part must run clockwise")
+		}
+		hs = append(hs, h)
+	}
+	ring, err := bridge(outer, hs)
+	if err != nil {
+		return nil, err
+	}
+	return earClip(ring)
+}
+
+// Cut an anticlockwise ring into triangles by cutting off one ear at a time.
+// An ear is a corner whose triangle holds no other point of the ring. The
+// ring may touch itself where holes have been bridged to it.
+func earClip(ring []gear.Point) ([][3]gear.Point, error) {
+	n := len(ring)
+	prev := make([]int, n)
+	next := make([]int, n)
+	for i := range ring {
+		prev[i] = (i + n - 1) % n
+		next[i] = (i + 1) % n
+	}
+	removed := make([]bool, n)
+	// Report whether no other point of the ring lies in the triangle at i.
+	isEar := func(i int) bool {
+		a, b, c := ring[prev[i]], ring[i], ring[next[i]]
+		lo := gear.Point{X: math.Min(a.X, math.Min(b.X, c.X)),
+			Y: math.Min(a.Y, math.Min(b.Y, c.Y))}
+		hi := gear.Point{X: math.Max(a.X, math.Max(b.X, c.X)),
+			Y: math.Max(a.Y, math.Max(b.Y, c.Y))}
+		for j, p := range ring {
+			if removed[j] || p.X < lo.X || p.X > hi.X || p.Y < lo.Y ||
+				p.Y > hi.Y || p == a || p == b || p == c {
+				continue
+			}
+			if cross(a, b, p) >= 0 && cross(b, c, p) >= 0 &&
+				cross(c, a, p) >= 0 {
+				return false
+			}
+		}
+		return true
+	}
+	var retval [][3]gear.Point
+	left, i, tried := n, 0, 0
+	for left > 2 {
+		a, b, c := ring[prev[i]], ring[i], ring[next[i]]
+		// A spike out and back along a bridge has no area and just goes.
+		// Corners in a straight line stay until they can go with a real
+		// triangle, so that the face meets the sides edge for edge.
+		spike := a == c
+		if spike || (!collinear(a, b, c) && cross(a, b, c) > 0 && isEar(i)) {
+			if !spike {
+				retval = append(retval, [3]gear.Point{a, b, c})
+			}
+			removed[i] = true
+			next[prev[i]], prev[next[i]] = next[i], prev[i]
+			left--
+			i, tried = next[i], 0
+			continue
+		}
+		i = next[i]
+		if tried++; tried > left {
+			// Whatever is left must have no area.
+			var rest []gear.Point
+			for j := 0; j < left; j, i = j+1, next[i] {
+				rest = append(rest, ring[i])
+			}
+			if math.Abs(area(rest)) > 1e-9 {
+				return nil, fmt.Errorf("cannot cut the face into triangles")
+			}
+			break
+		}
+	}
+	return retval, nil
+}